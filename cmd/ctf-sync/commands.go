@@ -3,25 +3,28 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"text/tabwriter"
+	"time"
 
 	"github.com/rw-r-r-0644/ctf-sync/jeopardy"
 )
 
 func runList(ctx context.Context, b jeopardy.Backend) error {
 	challenges, err := b.Fetch(ctx)
-	if err != nil {
+	if err := warnPartial(err); err != nil {
 		return err
 	}
 
 	// Try to fetch solves to mark status
-	if solves, err := b.Solves(ctx); err == nil {
+	if solves, err := b.Solves(ctx); warnPartial(err) == nil {
 		solvedMap := make(map[string]bool)
 		for _, s := range solves {
 			solvedMap[s.ChallengeID] = true
@@ -59,7 +62,26 @@ func runInfo(ctx context.Context, b jeopardy.Backend, id string) error {
 	if len(c.Tags) > 0 {
 		fmt.Printf("Tags:        %s\n", strings.Join(c.Tags, ", "))
 	}
-	fmt.Printf("Description:\n%s\n", c.Description)
+	desc := jeopardy.ParseDescription(c.Description)
+	fmt.Printf("Description:\n%s\n", jeopardy.RenderTerminal(desc.Markdown, useColor()))
+	if len(c.Hints) > 0 {
+		fmt.Println("Hints:")
+		for _, h := range c.Hints {
+			fmt.Printf("  - %s\n", h)
+		}
+	}
+	if len(c.Endpoints) > 0 {
+		fmt.Println("Connect:")
+		for i, ep := range c.Endpoints {
+			fmt.Printf("  %d. %s\n", i+1, ep.Command())
+		}
+	}
+	if len(c.Links) > 0 {
+		fmt.Println("Links:")
+		for _, l := range c.Links {
+			fmt.Printf("  - %s\n", l.URL)
+		}
+	}
 	if len(c.Files) > 0 {
 		fmt.Println("Files:")
 		for _, f := range c.Files {
@@ -69,35 +91,89 @@ func runInfo(ctx context.Context, b jeopardy.Backend, id string) error {
 	return nil
 }
 
-func runGet(ctx context.Context, b jeopardy.Backend, id string) error {
+// useColor reports whether stdout is a terminal that should get ANSI styles.
+func useColor() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// writeReadme writes a README.md with the challenge's rendered description.
+func writeReadme(dir string, c *jeopardy.Challenge, files []FileDTO) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", c.Name)
+	fmt.Fprintf(&b, "**Category:** %s · **Points:** %d\n\n", c.Category, c.Points)
+	if desc := jeopardy.ParseDescription(c.Description).Markdown; desc != "" {
+		fmt.Fprintf(&b, "%s\n\n", desc)
+	}
+	if len(c.Endpoints) > 0 {
+		b.WriteString("## Connection\n\n```\n")
+		for _, ep := range c.Endpoints {
+			fmt.Fprintf(&b, "%s\n", ep.Command())
+		}
+		b.WriteString("```\n\n")
+	}
+	if len(files) > 0 {
+		b.WriteString("## Files\n\n")
+		for _, f := range files {
+			fmt.Fprintf(&b, "- [%s](%s)\n", f.Name, url.PathEscape(nonEmptyString(f.Path, f.Name)))
+		}
+	}
+	return os.WriteFile(filepath.Join(dir, "README.md"), []byte(strings.TrimRight(b.String(), "\n")+"\n"), 0644)
+}
+
+func nonEmptyString(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+type FileDTO struct {
+	Name      string        `json:"name"`
+	Path      string        `json:"path,omitempty"`
+	Size      int64         `json:"size,omitempty"`
+	SHA256    string        `json:"sha256,omitempty"`
+	Extracted *ExtractedDTO `json:"extracted,omitempty"`
+}
+
+type ExtractedDTO struct {
+	Dir      string   `json:"dir,omitempty"`
+	Files    []string `json:"files,omitempty"`
+	Skipped  []string `json:"skipped,omitempty"`
+	Password string   `json:"password,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+type ChallengeDTO struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Category    string    `json:"category"`
+	Description string    `json:"description"`
+	Points      int       `json:"points"`
+	Tags        []string  `json:"tags"`
+	Hints       []string  `json:"hints,omitempty"`
+	Files       []FileDTO `json:"files"`
+	Solved      bool      `json:"solved"`
+}
+
+// runGet saves a challenge's info and files. Archives are unpacked when ex is non-nil.
+func runGet(ctx context.Context, b jeopardy.Backend, dl *jeopardy.Downloader, ex *jeopardy.Extractor, sc *Scaffolder, id string) error {
 	c, err := findChallenge(ctx, b, id)
 	if err != nil {
 		return err
 	}
 
-	dirName := sanitizeFilename(c.Name)
-	if dirName == "" {
-		dirName = c.ID
-	}
+	dirName := challengeDirName(c)
 
 	if err := os.MkdirAll(dirName, 0755); err != nil {
 		return fmt.Errorf("create directory: %w", err)
 	}
 
-	type FileDTO struct {
-		Name string `json:"name"`
-	}
-	type ChallengeDTO struct {
-		ID          string    `json:"id"`
-		Name        string    `json:"name"`
-		Category    string    `json:"category"`
-		Description string    `json:"description"`
-		Points      int       `json:"points"`
-		Tags        []string  `json:"tags"`
-		Files       []FileDTO `json:"files"`
-		Solved      bool      `json:"solved"`
-	}
-
 	dto := ChallengeDTO{
 		ID:          c.ID,
 		Name:        c.Name,
@@ -105,10 +181,30 @@ func runGet(ctx context.Context, b jeopardy.Backend, id string) error {
 		Description: c.Description,
 		Points:      c.Points,
 		Tags:        c.Tags,
+		Hints:       c.Hints,
 		Solved:      c.Solved,
 	}
+
+	var namer jeopardy.FileNamer
+	namer.Reserve("challenge.json")
+	namer.Reserve("README.md")
+	reqs := make([]jeopardy.DownloadRequest, 0, len(c.Files))
 	for _, f := range c.Files {
-		dto.Files = append(dto.Files, FileDTO{Name: f.Name()})
+		reqs = append(reqs, jeopardy.DownloadRequest{File: f, Path: filepath.Join(dirName, namer.Name(f.Name()))})
+	}
+	for _, res := range dl.Download(ctx, reqs) {
+		file := FileDTO{Name: res.File.Name(), Path: filepath.Base(res.Path)}
+		if res.Err != nil {
+			fmt.Printf("Error downloading %s: %v\n", res.File.Name(), res.Err)
+		} else {
+			fmt.Printf("Downloaded %s\n", res.File.Name())
+			file.Size = res.Size
+			file.SHA256 = res.SHA256
+			if ex != nil && jeopardy.IsArchive(res.Path) {
+				file.Extracted = extractArchive(ctx, ex, res.Path)
+			}
+		}
+		dto.Files = append(dto.Files, file)
 	}
 
 	jsonData, err := json.MarshalIndent(dto, "", "  ")
@@ -122,18 +218,32 @@ func runGet(ctx context.Context, b jeopardy.Backend, id string) error {
 
 	fmt.Printf("Saved challenge info to %s/challenge.json\n", dirName)
 
-	for _, f := range c.Files {
-		if err := downloadFile(ctx, f, dirName); err != nil {
-			fmt.Printf("Error downloading %s: %v\n", f.Name(), err)
-		} else {
-			fmt.Printf("Downloaded %s\n", f.Name())
-		}
+	if err := writeReadme(dirName, c, dto.Files); err != nil {
+		return fmt.Errorf("write README.md: %w", err)
 	}
 
+	if sc != nil {
+		var downloads []string
+		for _, f := range dto.Files {
+			if f.SHA256 != "" {
+				downloads = append(downloads, f.Path)
+			}
+		}
+		written, skipped, err := sc.Scaffold(dirName, c, downloads)
+		if err != nil {
+			return fmt.Errorf("scaffold: %w", err)
+		}
+		for _, name := range written {
+			fmt.Printf("Created %s/%s\n", dirName, name)
+		}
+		for _, name := range skipped {
+			fmt.Printf("Skipped %s/%s: already exists\n", dirName, name)
+		}
+	}
 	return nil
 }
 
-func runGetFile(ctx context.Context, b jeopardy.Backend, args []string) error {
+func runGetFile(ctx context.Context, b jeopardy.Backend, dl *jeopardy.Downloader, ex *jeopardy.Extractor, args []string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("usage: get-file <challenge-id> <filename>")
 	}
@@ -157,10 +267,16 @@ func runGetFile(ctx context.Context, b jeopardy.Backend, args []string) error {
 		return fmt.Errorf("file %s not found in challenge %s", fileName, challID)
 	}
 
-	if err := downloadFile(ctx, targetFile, "."); err != nil {
+	var namer jeopardy.FileNamer
+	outPath := namer.Name(fileName)
+	res, err := dl.DownloadFile(ctx, targetFile, outPath)
+	if err != nil {
 		return err
 	}
-	fmt.Printf("Downloaded %s\n", fileName)
+	fmt.Printf("Downloaded %s (sha256 %s)\n", outPath, res.SHA256)
+	if ex != nil && jeopardy.IsArchive(outPath) {
+		extractArchive(ctx, ex, outPath)
+	}
 	return nil
 }
 
@@ -196,9 +312,71 @@ func runSubmit(ctx context.Context, b jeopardy.Backend, args []string) error {
 	return nil
 }
 
+func runSolves(ctx context.Context, b jeopardy.Backend) error {
+	solves, err := b.Solves(ctx)
+	if err := warnPartial(err); err != nil {
+		return err
+	}
+
+	// Challenge names and points are only known from Fetch; solves still print without them.
+	byID := make(map[string]jeopardy.Challenge)
+	if challenges, err := b.Fetch(ctx); warnPartial(err) == nil {
+		for _, c := range challenges {
+			byID[c.ID] = c
+		}
+	}
+
+	// Group solves by the member who solved them, keeping first-seen order.
+	var members []string
+	groups := make(map[string][]jeopardy.Solve)
+	for _, s := range solves {
+		member := s.UserName
+		if member == "" {
+			member = s.UserID
+		}
+		if member == "" {
+			member = "(unknown)"
+		}
+		if _, ok := groups[member]; !ok {
+			members = append(members, member)
+		}
+		groups[member] = append(groups[member], s)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	for i, member := range members {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		total := 0
+		for _, s := range groups[member] {
+			total += solvePoints(s, byID)
+		}
+		fmt.Fprintf(w, "%s (%d solves, %d points)\n", member, len(groups[member]), total)
+		fmt.Fprintln(w, "  ID\tName\tCategory\tPoints\tSolved At")
+		for _, s := range groups[member] {
+			c := byID[s.ChallengeID]
+			solvedAt := "-"
+			if s.SolvedAt != nil {
+				solvedAt = s.SolvedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%d\t%s\n", s.ChallengeID, c.Name, c.Category, solvePoints(s, byID), solvedAt)
+		}
+	}
+	return w.Flush()
+}
+
+// solvePoints prefers the points awarded for the solve and falls back to the challenge value.
+func solvePoints(s jeopardy.Solve, byID map[string]jeopardy.Challenge) int {
+	if s.Points != 0 {
+		return s.Points
+	}
+	return byID[s.ChallengeID].Points
+}
+
 func findChallenge(ctx context.Context, b jeopardy.Backend, id string) (*jeopardy.Challenge, error) {
 	challenges, err := b.Fetch(ctx)
-	if err != nil {
+	if err := warnPartial(err); err != nil {
 		return nil, err
 	}
 	for i := range challenges {
@@ -209,41 +387,95 @@ func findChallenge(ctx context.Context, b jeopardy.Backend, id string) (*jeopard
 	return nil, fmt.Errorf("challenge %s not found", id)
 }
 
-func downloadFile(ctx context.Context, f jeopardy.File, dir string) error {
-	info, err := f.DownloadURL(ctx)
+// extractArchive unpacks a downloaded archive and reports what happened,
+// both on stdout and in the form recorded in challenge.json.
+func extractArchive(ctx context.Context, ex *jeopardy.Extractor, path string) *ExtractedDTO {
+	res, err := ex.Extract(ctx, path)
 	if err != nil {
-		return fmt.Errorf("get download url: %w", err)
+		fmt.Printf("Error extracting %s: %v\n", filepath.Base(path), err)
+		return &ExtractedDTO{Error: err.Error()}
 	}
-
-	req, err := http.NewRequestWithContext(ctx, "GET", info.URL, nil)
-	if err != nil {
-		return err
+	fmt.Printf("Extracted %s to %s (%d files)\n", filepath.Base(path), res.Dir, len(res.Files))
+	for _, s := range res.Skipped {
+		fmt.Printf("  skipped %s\n", s)
 	}
-	for k, v := range info.Headers {
-		req.Header.Set(k, v)
+	return &ExtractedDTO{
+		Dir:      filepath.Base(res.Dir),
+		Files:    res.Files,
+		Skipped:  res.Skipped,
+		Password: res.Password,
 	}
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
+// progressPrinter returns a Downloader progress callback that prints at most
+// one line per file per second to stderr.
+func progressPrinter() func(jeopardy.Progress) {
+	var mu sync.Mutex
+	last := make(map[string]time.Time)
+	return func(p jeopardy.Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		if p.Done {
+			delete(last, p.Path)
+			return
+		}
+		t, seen := last[p.Path]
+		if !seen {
+			// Start the clock on the first chunk so small files stay quiet.
+			last[p.Path] = time.Now()
+			return
+		}
+		if time.Since(t) < time.Second {
+			return
+		}
+		last[p.Path] = time.Now()
+		if p.Total > 0 {
+			fmt.Fprintf(os.Stderr, "  %s: %s / %s (%d%%)\n", p.Name, formatBytes(p.Downloaded), formatBytes(p.Total), p.Downloaded*100/p.Total)
+		} else {
+			fmt.Fprintf(os.Stderr, "  %s: %s\n", p.Name, formatBytes(p.Downloaded))
+		}
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed: %s", resp.Status)
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
 	}
-
-	outPath := filepath.Join(dir, f.Name())
-	outFile, err := os.Create(outPath)
-	if err != nil {
-		return err
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
 	}
-	defer outFile.Close()
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
 
-	_, err = io.Copy(outFile, resp.Body)
-	return err
+// challengeDirName picks a safe directory name for a challenge, falling back
+// to its ID when the name has nothing usable in it.
+func challengeDirName(c *jeopardy.Challenge) string {
+	if name := jeopardy.SafeFilename(c.Name); name != "" {
+		return name
+	}
+	if id := jeopardy.SafeFilename(c.ID); id != "" {
+		return id
+	}
+	return "challenge"
 }
 
-func sanitizeFilename(name string) string {
-	return strings.ReplaceAll(strings.ReplaceAll(name, "/", "_"), "\\", "_")
+// warnPartial prints which of an aggregate's profiles failed and clears the
+// error, so the other profiles' results are still used.
+func warnPartial(err error) error {
+	var partial *jeopardy.PartialError
+	if !errors.As(err, &partial) {
+		return err
+	}
+	names := make([]string, 0, len(partial.Failed))
+	for name := range partial.Failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", name, partial.Failed[name])
+	}
+	return nil
 }
@@ -1,15 +1,40 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
 type Config struct {
 	Backend string            `json:"backend"`
 	Config  map[string]string `json:"config"`
+
+	// ArchivePasswords are tried on encrypted archives when extracting.
+	ArchivePasswords []string `json:"archive_passwords"`
+
+	// TemplatesDir holds the user's solve templates for -scaffold.
+	TemplatesDir string `json:"templates_dir"`
+
+	// Profiles are named backend configurations, picked with -profile or
+	// combined by the aggregate backend.
+	Profiles map[string]Profile `json:"profiles"`
+}
+
+// Profile is one platform and account.
+type Profile struct {
+	Backend string            `json:"backend"`
+	Config  map[string]string `json:"config"`
 }
 
+// defaultArchivePasswords covers the usual convention for malware handouts.
+var defaultArchivePasswords = []string{"infected"}
+
 func loadConfig(path string) (*Config, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -29,3 +54,70 @@ func loadConfig(path string) (*Config, error) {
 	}
 	return &cfg, nil
 }
+
+// useProfiles makes cfg use the named profiles: a single one directly,
+// several through the aggregate backend.
+func (cfg *Config) useProfiles(names []string) error {
+	if len(names) == 1 {
+		p, ok := cfg.Profiles[names[0]]
+		if !ok {
+			return fmt.Errorf("unknown profile %q", names[0])
+		}
+		cfg.Backend = p.Backend
+		cfg.Config = make(map[string]string, len(p.Config))
+		for k, v := range p.Config {
+			cfg.Config[k] = v
+		}
+		return nil
+	}
+	cfg.Backend = "aggregate"
+	cfg.Config = map[string]string{"profiles": strings.Join(names, ",")}
+	return nil
+}
+
+// expandProfiles fills in the aggregate backend's per-profile settings
+// (<name>.backend, <name>.<setting>) from the config's profiles. Settings
+// already given, e.g. with -S, are kept.
+func (cfg *Config) expandProfiles() error {
+	if cfg.Backend != "aggregate" {
+		return nil
+	}
+	for _, name := range strings.Split(cfg.Config["profiles"], ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || cfg.Config[name+".backend"] != "" {
+			continue
+		}
+		p, ok := cfg.Profiles[name]
+		if !ok {
+			return fmt.Errorf("unknown profile %q", name)
+		}
+		cfg.Config[name+".backend"] = p.Backend
+		for k, v := range p.Config {
+			if _, set := cfg.Config[name+"."+k]; !set {
+				cfg.Config[name+"."+k] = v
+			}
+		}
+	}
+	return nil
+}
+
+// cachePath returns the cache file for cfg. Each backend and settings
+// combination (platform, account) gets its own file.
+func cachePath(cfg *Config) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	keys := make([]string, 0, len(cfg.Config))
+	for k := range cfg.Config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	h.Write([]byte(cfg.Backend))
+	for _, k := range keys {
+		h.Write([]byte("\x00" + k + "=" + cfg.Config[k]))
+	}
+	name := cfg.Backend + "-" + hex.EncodeToString(h.Sum(nil))[:12] + ".json"
+	return filepath.Join(dir, "ctf-sync", name), nil
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rw-r-r-0644/ctf-sync/jeopardy"
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy/replay"
 )
 
 type kvFlag map[string]string
@@ -29,17 +33,64 @@ func (k kvFlag) Set(value string) error {
 	return nil
 }
 
+// byteSize is a flag.Value accepting sizes like 100B, 512K, 100M or 2G;
+// see jeopardy.ParseSize.
+type byteSize int64
+
+func (b *byteSize) String() string {
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+func (b *byteSize) Set(value string) error {
+	n, err := jeopardy.ParseSize(value)
+	if err != nil {
+		return err
+	}
+	*b = byteSize(n)
+	return nil
+}
+
 func main() {
 	var (
 		backendID  string
 		configPath string
 		settings   = make(kvFlag)
+		parallel   int
+		maxSize    byteSize
+		extract    bool
+		passwords  string
+		scaffold   bool
+		templates  string
+		offline    bool
+		cacheTTL   time.Duration
+		verbose    bool
+		dryRun     bool
+		readOnly   bool
+		debug      bool
+		harPath    string
+		recordPath string
+		profiles   string
 	)
 
 	fs := flag.NewFlagSet("ctf-sync", flag.ExitOnError)
 	fs.StringVar(&backendID, "backend", "", "Backend ID (e.g. ctfd_token, rctf)")
 	fs.StringVar(&configPath, "config", "ctf-sync.json", "Path to config file")
+	fs.StringVar(&profiles, "profile", "", "Profile from the config to use; several comma-separated ones are aggregated")
 	fs.Var(settings, "S", "Backend settings (key=value), can be repeated")
+	fs.IntVar(&parallel, "parallel", 4, "Number of files to download at once")
+	fs.Var(&maxSize, "max-size", "Maximum size of a downloaded file, e.g. 2G (0 for no limit)")
+	fs.BoolVar(&extract, "extract", false, "Unpack downloaded archives next to them")
+	fs.BoolVar(&offline, "offline", false, "Serve challenges and solves from the local cache only")
+	fs.DurationVar(&cacheTTL, "cache-ttl", 0, "How long cached challenges and solves are used before asking the platform again (0 always asks; the cache still serves them when the platform is down)")
+	fs.BoolVar(&verbose, "v", false, "Log backend calls to stderr")
+	fs.BoolVar(&debug, "debug", false, "Log every HTTP request and response to stderr, credentials redacted")
+	fs.StringVar(&harPath, "har", "", "Write the HTTP traffic, credentials redacted, to this HAR file")
+	fs.StringVar(&recordPath, "record", "", "Record the platform traffic, credentials redacted, to this replay fixture (bypasses the cache)")
+	fs.BoolVar(&dryRun, "dry-run", false, "Don't send submissions, just report them")
+	fs.BoolVar(&readOnly, "read-only", false, "Refuse to submit flags")
+	fs.BoolVar(&scaffold, "scaffold", false, "Generate a solve template when getting a challenge")
+	fs.StringVar(&templates, "templates", "", "Directory of solve templates (default from config, or the user config dir)")
+	fs.StringVar(&passwords, "passwords", "", "Comma-separated passwords to try on encrypted archives (default from config, or \"infected\")")
 
 	fs.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [global options] object [args...]\n", os.Args[0])
@@ -50,6 +101,12 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  info <id>        Show challenge info\n")
 		fmt.Fprintf(os.Stderr, "  get <id>         Download challenge files and info\n")
 		fmt.Fprintf(os.Stderr, "  get-file <id> <file> Download a specific file\n")
+		fmt.Fprintf(os.Stderr, "  submit <id> <flag> Submit a flag\n")
+		fmt.Fprintf(os.Stderr, "  solves           List solves grouped by team member\n")
+		fmt.Fprintf(os.Stderr, "  watch [-interval d] Report new and solved challenges until interrupted\n")
+		fmt.Fprintf(os.Stderr, "  connect [-pwntools] <id> [n] Connect to a challenge service\n")
+		fmt.Fprintf(os.Stderr, "  writeups [-out dir] [-format hugo|jekyll] [-all] Generate writeup skeletons\n")
+		fmt.Fprintf(os.Stderr, "  mock-server [-addr a] [-api ctfd|rctf|ccit] <file.yaml> Serve a fake platform\n")
 	}
 
 	if len(os.Args) < 2 {
@@ -74,6 +131,14 @@ func main() {
 	cmdName := fs.Arg(0)
 	cmdArgs := fs.Args()[1:]
 
+	if cmdName == "mock-server" {
+		if err := runMockServer(cmdArgs); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Load config
 	cfg, err := loadConfig(configPath)
 	if err != nil {
@@ -82,26 +147,110 @@ func main() {
 	}
 
 	// Merge flags into config
+	if profiles != "" {
+		if err := cfg.useProfiles(strings.Split(profiles, ",")); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
 	if backendID != "" {
 		cfg.Backend = backendID
 	}
 	for k, v := range settings {
 		cfg.Config[k] = v
 	}
+	if err := cfg.expandProfiles(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
 	if cfg.Backend == "" {
 		fmt.Fprintf(os.Stderr, "Error: backend type is required (via -backend or config file)\n")
 		os.Exit(1)
 	}
 
+	var mw []jeopardy.Middleware
+	switch {
+	case dryRun && readOnly:
+		fmt.Fprintf(os.Stderr, "Error: -dry-run and -read-only are mutually exclusive\n")
+		os.Exit(1)
+	case dryRun:
+		mw = append(mw, jeopardy.DryRun())
+	case readOnly:
+		mw = append(mw, jeopardy.ReadOnly())
+	}
+	// A recording must see every call, so it skips the cache.
+	if recordPath != "" {
+		if offline {
+			fmt.Fprintf(os.Stderr, "Error: -offline and -record are mutually exclusive\n")
+			os.Exit(1)
+		}
+	} else if path, err := cachePath(cfg); err == nil {
+		mw = append(mw, (&jeopardy.Cache{
+			Path:    path,
+			TTL:     cacheTTL,
+			Offline: offline,
+			Warn: func(err error) {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			},
+		}).Wrap)
+	} else if offline {
+		fmt.Fprintf(os.Stderr, "Error: -offline needs a cache directory: %v\n", err)
+		os.Exit(1)
+	}
+	// Logging goes last so it shows the calls that reach the platform.
+	if verbose {
+		mw = append(mw, jeopardy.Logging(slog.New(slog.NewTextHandler(os.Stderr, nil))))
+	}
+
 	// Create backend
 	b, err := jeopardy.Build(cfg.Backend, cfg.Config)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating backend: %v\n", err)
 		os.Exit(1)
 	}
+	b = jeopardy.Wrap(b, mw...)
+
+	dl := &jeopardy.Downloader{
+		Concurrency: parallel,
+		MaxSize:     int64(maxSize),
+		Progress:    progressPrinter(),
+	}
+
+	var ex *jeopardy.Extractor
+	if extract {
+		ex = &jeopardy.Extractor{Passwords: cfg.ArchivePasswords}
+		if passwords != "" {
+			ex.Passwords = strings.Split(passwords, ",")
+		}
+		if ex.Passwords == nil {
+			ex.Passwords = defaultArchivePasswords
+		}
+	}
+
+	var sc *Scaffolder
+	if scaffold {
+		sc = &Scaffolder{Dir: templates}
+		if sc.Dir == "" {
+			sc.Dir = cfg.TemplatesDir
+		}
+		if sc.Dir == "" {
+			sc.Dir = defaultTemplateDir()
+		}
+	}
 
 	ctx := context.Background()
+	var tracer *jeopardy.Tracer
+	if debug || harPath != "" || recordPath != "" {
+		tracer = &jeopardy.Tracer{}
+		if recordPath != "" {
+			tracer.MaxBody = replay.MaxBody
+		}
+		if debug {
+			tracer.Log = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		}
+		ctx = jeopardy.WithTracer(ctx, tracer)
+	}
 	var cmdErr error
 
 	switch cmdName {
@@ -117,13 +266,13 @@ func main() {
 		if len(cmdArgs) < 1 {
 			cmdErr = fmt.Errorf("usage: get <chall-id>")
 		} else {
-			cmdErr = runGet(ctx, b, cmdArgs[0])
+			cmdErr = runGet(ctx, b, dl, ex, sc, cmdArgs[0])
 		}
 	case "get-file":
 		if len(cmdArgs) < 2 {
 			cmdErr = fmt.Errorf("usage: get-file <chall-id> <file-name>")
 		} else {
-			cmdErr = runGetFile(ctx, b, cmdArgs)
+			cmdErr = runGetFile(ctx, b, dl, ex, cmdArgs)
 		}
 	case "submit":
 		if len(cmdArgs) < 2 {
@@ -131,12 +280,48 @@ func main() {
 		} else {
 			cmdErr = runSubmit(ctx, b, cmdArgs)
 		}
+	case "solves":
+		cmdErr = runSolves(ctx, b)
+	case "connect":
+		cmdErr = runConnect(ctx, b, cmdArgs)
+	case "watch":
+		cmdErr = runWatch(ctx, b, cmdArgs)
+	case "writeups":
+		cmdErr = runWriteups(ctx, b, cmdArgs)
 	default:
 		cmdErr = fmt.Errorf("unknown command: %s", cmdName)
 	}
 
+	if harPath != "" {
+		if err := writeHAR(harPath, tracer); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing HAR: %v\n", err)
+		}
+	}
+	if recordPath != "" {
+		if err := replay.FromTrace(cfg.Config["base_url"], tracer.Entries()).Save(recordPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing fixture: %v\n", err)
+		}
+	}
+
+	// Stops a persistent script and removes its sandbox.
+	if err := jeopardy.Close(b); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: closing backend: %v\n", err)
+	}
+
 	if cmdErr != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", cmdErr)
 		os.Exit(1)
 	}
 }
+
+func writeHAR(path string, tracer *jeopardy.Tracer) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := tracer.WriteHAR(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy/mockserver"
+)
+
+// mockBackends maps the served API to the backend and settings that talk
+// to it, for the hint printed at startup.
+var mockBackends = map[string]string{
+	"ctfd": "-backend ctfd_token -S token=%s",
+	"rctf": "-backend rctf -S team_token=%s",
+	"ccit": "-backend ccit -S token=%s -S x-version=mock",
+}
+
+// runMockServer serves a fake platform from a YAML definition until killed.
+// It needs no backend, so main runs it before loading the config.
+func runMockServer(args []string) error {
+	fs := flag.NewFlagSet("mock-server", flag.ContinueOnError)
+	addr := fs.String("addr", "localhost:8000", "Address to listen on")
+	api := fs.String("api", "ctfd", "API to serve: "+strings.Join(mockserver.APIs, ", "))
+	quiet := fs.Bool("q", false, "Don't log requests")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: mock-server [-addr host:port] [-api ctfd|rctf|ccit] [-q] <challenges.yaml>")
+	}
+
+	p, err := mockserver.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	h, err := mockserver.Handler(*api, p)
+	if err != nil {
+		return err
+	}
+	if !*quiet {
+		h = logRequests(h)
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	token := p.Token
+	if token == "" {
+		token = "anything"
+	}
+	baseURL := "http://" + ln.Addr().String()
+	fmt.Fprintf(os.Stderr, "Serving %d challenges with the %s API on %s\n", len(p.Challenges), *api, baseURL)
+	fmt.Fprintf(os.Stderr, "Try: ctf-sync %s -S base_url=%s list\n", fmt.Sprintf(mockBackends[*api], token), baseURL)
+	return http.Serve(ln, h)
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		fmt.Fprintf(os.Stderr, "%s %s %d\n", r.Method, r.URL.Path, rec.status)
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy"
+)
+
+//go:embed templates
+var builtinTemplates embed.FS
+
+// categoryAliases maps the category names platforms use to template keys.
+var categoryAliases = map[string]string{
+	"binary":              "pwn",
+	"binary exploitation": "pwn",
+	"exploitation":        "pwn",
+	"pwnable":             "pwn",
+	"cryptography":        "crypto",
+	"web exploitation":    "web",
+	"web security":        "web",
+}
+
+// protocolKeys maps endpoint protocols to template keys, tried after the
+// category so a "misc" challenge with a nc endpoint still gets pwntools.
+var protocolKeys = map[jeopardy.Protocol][]string{
+	jeopardy.ProtocolTCP:    {"tcp", "pwn"},
+	jeopardy.ProtocolTelnet: {"tcp", "pwn"},
+	jeopardy.ProtocolSSH:    {"ssh"},
+	jeopardy.ProtocolHTTP:   {"http", "web"},
+	jeopardy.ProtocolHTTPS:  {"http", "web"},
+}
+
+// Scaffolder renders solve templates into challenge directories. A template
+// is a directory named after a key (category, alias or protocol, see
+// templateKeys) whose files are rendered with text/template; a ".tmpl"
+// suffix is dropped from the output name. Dir is searched first, then the
+// built-in pwn, web and crypto templates.
+type Scaffolder struct {
+	Dir string
+}
+
+// ScaffoldData is what templates see: the challenge fields, plus the first
+// endpoint and the names of the downloaded files.
+type ScaffoldData struct {
+	*jeopardy.Challenge
+	Host      string
+	Port      int
+	TLS       bool
+	URL       string
+	Downloads []string
+	Binary    string // first download that looks like an executable
+}
+
+var templateFuncs = template.FuncMap{
+	"quote": strconv.Quote,
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+}
+
+// Scaffold renders the first matching template into dir and returns the
+// files it wrote and the ones it skipped. Existing files are left alone, so
+// re-running get never clobbers a solve in progress.
+func (s *Scaffolder) Scaffold(dir string, c *jeopardy.Challenge, downloads []string) (written, skipped []string, err error) {
+	fsys, root, ok := s.find(templateKeys(c))
+	if !ok {
+		return nil, nil, nil
+	}
+
+	data := newScaffoldData(c, downloads)
+	err = fs.WalkDir(fsys, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		src, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		tmpl, err := template.New(p).Funcs(templateFuncs).Parse(string(src))
+		if err != nil {
+			return fmt.Errorf("parse template: %w", err)
+		}
+
+		rel := strings.TrimSuffix(strings.TrimPrefix(p, root+"/"), ".tmpl")
+		out := filepath.Join(dir, filepath.FromSlash(rel))
+		if _, err := os.Stat(out); err == nil {
+			skipped = append(skipped, rel)
+			return nil
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return fmt.Errorf("render %s: %w", rel, err)
+		}
+		perm := os.FileMode(0644)
+		if strings.HasPrefix(b.String(), "#!") {
+			perm = 0755
+		}
+		if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(out, []byte(b.String()), perm); err != nil {
+			return err
+		}
+		written = append(written, rel)
+		return nil
+	})
+	return written, skipped, err
+}
+
+// find returns the template directory for the first key found, looking in
+// the user directory before the built-in templates.
+func (s *Scaffolder) find(keys []string) (fs.FS, string, bool) {
+	var sources []fs.FS
+	if s.Dir != "" {
+		sources = append(sources, os.DirFS(s.Dir))
+	}
+	builtin, _ := fs.Sub(builtinTemplates, "templates")
+	sources = append(sources, builtin)
+
+	for _, key := range keys {
+		for _, fsys := range sources {
+			if fi, err := fs.Stat(fsys, key); err == nil && fi.IsDir() {
+				return fsys, key, true
+			}
+		}
+	}
+	return nil, "", false
+}
+
+// templateKeys lists the template names to try for c, most specific first:
+// the category, its alias, the first endpoint's protocol, then "default".
+func templateKeys(c *jeopardy.Challenge) []string {
+	var keys []string
+	if cat := jeopardy.SafeFilename(strings.ToLower(strings.TrimSpace(c.Category))); cat != "" {
+		keys = append(keys, cat)
+		if alias, ok := categoryAliases[strings.ToLower(strings.TrimSpace(c.Category))]; ok {
+			keys = append(keys, alias)
+		}
+	}
+	if len(c.Endpoints) > 0 {
+		keys = append(keys, protocolKeys[c.Endpoints[0].Protocol]...)
+	}
+	return append(keys, "default")
+}
+
+func newScaffoldData(c *jeopardy.Challenge, downloads []string) ScaffoldData {
+	data := ScaffoldData{Challenge: c, Downloads: downloads}
+	for _, ep := range c.Endpoints {
+		if ep.URL != "" {
+			if data.URL == "" {
+				data.URL = ep.URL
+			}
+			continue
+		}
+		if data.Host == "" {
+			data.Host, data.Port, data.TLS = ep.Host, ep.Port, ep.TLS
+		}
+	}
+	if data.Host == "" && len(c.Endpoints) > 0 {
+		data.Host, data.Port = c.Endpoints[0].Host, c.Endpoints[0].Port
+	}
+	for _, name := range downloads {
+		if ext := strings.ToLower(path.Ext(name)); ext == "" || ext == ".elf" || ext == ".bin" || ext == ".out" {
+			data.Binary = name
+			break
+		}
+	}
+	return data
+}
+
+// defaultTemplateDir is where templates are looked up when neither -templates
+// nor the config names a directory.
+func defaultTemplateDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "ctf-sync", "templates")
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy"
+)
+
+// watchState is what one poll saw: challenges by ID, and who solved what.
+type watchState struct {
+	challenges map[string]jeopardy.Challenge
+	solvedBy   map[string][]string // challenge ID; empty when the solver isn't known
+}
+
+// runWatch polls the backend until interrupted and prints challenges that
+// appear and get solved. With -profile naming several CTFs it watches all
+// of them; a profile that is down only gets a warning.
+func runWatch(ctx context.Context, b jeopardy.Backend, args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	interval := fs.Duration("interval", time.Minute, "Time between polls")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("invalid -interval %s", *interval)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	prev, err := pollWatch(ctx, b)
+	if err != nil {
+		return err
+	}
+	solved := 0
+	for _, c := range prev.challenges {
+		if _, ok := prev.solvedBy[c.ID]; ok {
+			solved++
+		}
+	}
+	fmt.Printf("%s Watching %d challenges (%d solved), every %s\n", time.Now().Format("15:04:05"), len(prev.challenges), solved, *interval)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		cur, err := pollWatch(ctx, b)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			// The platform may just be busy; try again next time.
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		printWatchChanges(prev, cur)
+		prev = cur
+	}
+}
+
+func pollWatch(ctx context.Context, b jeopardy.Backend) (*watchState, error) {
+	challenges, err := b.Fetch(ctx)
+	if err := warnPartial(err); err != nil {
+		return nil, err
+	}
+	solves, err := b.Solves(ctx)
+	if err := warnPartial(err); err != nil {
+		return nil, err
+	}
+
+	s := &watchState{challenges: make(map[string]jeopardy.Challenge), solvedBy: make(map[string][]string)}
+	for _, c := range challenges {
+		s.challenges[c.ID] = c
+		if c.Solved {
+			s.solvedBy[c.ID] = nil
+		}
+	}
+	for _, solve := range solves {
+		by := s.solvedBy[solve.ChallengeID]
+		if name := nonEmptyString(solve.UserName, solve.UserID); name != "" && !slices.Contains(by, name) {
+			by = append(by, name)
+		}
+		s.solvedBy[solve.ChallengeID] = by
+	}
+	return s, nil
+}
+
+// printWatchChanges prints challenges in cur that weren't in prev, and
+// ones solved since, sorted by ID.
+func printWatchChanges(prev, cur *watchState) {
+	now := time.Now().Format("15:04:05")
+	ids := make([]string, 0, len(cur.challenges))
+	for id := range cur.challenges {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		c := cur.challenges[id]
+		if _, ok := prev.challenges[id]; !ok {
+			fmt.Printf("%s New:    %s  %s (%s, %d points)\n", now, c.ID, c.Name, nonEmptyString(c.Category, "uncategorized"), c.Points)
+		}
+		by, solved := cur.solvedBy[id]
+		if _, was := prev.solvedBy[id]; solved && !was {
+			fmt.Printf("%s Solved: %s  %s", now, c.ID, c.Name)
+			if len(by) > 0 {
+				fmt.Printf(" by %s", strings.Join(by, ", "))
+			}
+			fmt.Println()
+		}
+	}
+}
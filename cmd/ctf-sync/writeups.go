@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy"
+)
+
+// writeup is a solved challenge with the solve data merged in.
+type writeup struct {
+	Challenge jeopardy.Challenge
+	SolvedAt  *time.Time
+	SolvedBy  []string
+	Points    int
+	File      string
+}
+
+func runWriteups(ctx context.Context, b jeopardy.Backend, args []string) error {
+	fs := flag.NewFlagSet("writeups", flag.ContinueOnError)
+	outDir := fs.String("out", "writeups", "Output directory")
+	format := fs.String("format", "hugo", "Front matter and file layout: hugo or jekyll")
+	all := fs.Bool("all", false, "Include unsolved challenges")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "hugo" && *format != "jekyll" {
+		return fmt.Errorf("unknown format %q (want hugo or jekyll)", *format)
+	}
+
+	challenges, err := b.Fetch(ctx)
+	if err := warnPartial(err); err != nil {
+		return err
+	}
+	solves, err := b.Solves(ctx)
+	if err := warnPartial(err); err != nil {
+		return err
+	}
+
+	byID := make(map[string]jeopardy.Challenge, len(challenges))
+	for _, c := range challenges {
+		byID[c.ID] = c
+	}
+	solvesByID := make(map[string][]jeopardy.Solve)
+	for _, s := range solves {
+		solvesByID[s.ChallengeID] = append(solvesByID[s.ChallengeID], s)
+	}
+
+	var writeups []*writeup
+	for _, c := range challenges {
+		cs := solvesByID[c.ID]
+		if !*all && !c.Solved && len(cs) == 0 {
+			continue
+		}
+		w := &writeup{Challenge: c, Points: c.Points}
+		for _, s := range cs {
+			if s.SolvedAt != nil && (w.SolvedAt == nil || s.SolvedAt.Before(*w.SolvedAt)) {
+				w.SolvedAt = s.SolvedAt
+			}
+			if name := nonEmptyString(s.UserName, s.UserID); name != "" && !slices.Contains(w.SolvedBy, name) {
+				w.SolvedBy = append(w.SolvedBy, name)
+			}
+		}
+		if len(cs) > 0 {
+			w.Points = solvePoints(cs[0], byID)
+		}
+		writeups = append(writeups, w)
+	}
+	sort.SliceStable(writeups, func(i, j int) bool {
+		ci, cj := strings.ToLower(writeups[i].Challenge.Category), strings.ToLower(writeups[j].Challenge.Category)
+		if ci != cj {
+			return ci < cj
+		}
+		return strings.ToLower(writeups[i].Challenge.Name) < strings.ToLower(writeups[j].Challenge.Name)
+	})
+
+	// Jekyll only turns files under _posts into posts; the index is a
+	// page next to it.
+	postDir := *outDir
+	if *format == "jekyll" {
+		postDir = filepath.Join(*outDir, "_posts")
+	}
+	if err := os.MkdirAll(postDir, 0755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+
+	var namer jeopardy.FileNamer
+	namer.Reserve("index.md")
+	namer.Reserve("_index.md")
+	created := 0
+	for _, w := range writeups {
+		w.File = namer.Name(writeupFileName(w, *format, postDir))
+		path := filepath.Join(postDir, w.File)
+		// Writeups get edited by hand; never overwrite one.
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(renderWriteup(w)), 0644); err != nil {
+			return fmt.Errorf("write %s: %w", w.File, err)
+		}
+		created++
+	}
+
+	indexName := "_index.md"
+	if *format == "jekyll" {
+		indexName = "index.md"
+	}
+	if err := os.WriteFile(filepath.Join(*outDir, indexName), []byte(renderWriteupIndex(writeups, *format)), 0644); err != nil {
+		return fmt.Errorf("write %s: %w", indexName, err)
+	}
+
+	fmt.Printf("Wrote %d new writeups (%d total) and %s to %s\n", created, len(writeups), indexName, *outDir)
+	return nil
+}
+
+var slugRe = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+func slugify(s string) string {
+	return strings.Trim(slugRe.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// writeupFileName returns the Markdown file name for w. Jekyll only picks up
+// posts named YYYY-MM-DD-slug.md: the date is the solve time's, or else
+// that of a post for the same slug an earlier run wrote to dir, so reruns
+// find it instead of writing another one dated today.
+func writeupFileName(w *writeup, format, dir string) string {
+	slug := nonEmptyString(slugify(w.Challenge.Name), slugify(w.Challenge.ID), "challenge")
+	if format != "jekyll" {
+		return slug + ".md"
+	}
+	existing, _ := filepath.Glob(filepath.Join(dir, "[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9]-"+slug+".md"))
+	for i := range existing {
+		existing[i] = filepath.Base(existing[i])
+	}
+	sort.Strings(existing)
+	if w.SolvedAt != nil {
+		name := w.SolvedAt.Format("2006-01-02") + "-" + slug + ".md"
+		if len(existing) == 0 || slices.Contains(existing, name) {
+			return name
+		}
+	}
+	if len(existing) > 0 {
+		return existing[0]
+	}
+	return time.Now().Format("2006-01-02") + "-" + slug + ".md"
+}
+
+func renderWriteup(w *writeup) string {
+	c := w.Challenge
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "title: %s\n", strconv.Quote(c.Name))
+	if w.SolvedAt != nil {
+		fmt.Fprintf(&b, "date: %s\n", w.SolvedAt.Format(time.RFC3339))
+	}
+	fmt.Fprintf(&b, "categories: [%s]\n", quoteList([]string{c.Category}))
+	if len(c.Tags) > 0 {
+		fmt.Fprintf(&b, "tags: [%s]\n", quoteList(c.Tags))
+	}
+	fmt.Fprintf(&b, "points: %d\n", w.Points)
+	if len(w.SolvedBy) > 0 {
+		fmt.Fprintf(&b, "authors: [%s]\n", quoteList(w.SolvedBy))
+	}
+	b.WriteString("draft: true\n")
+	b.WriteString("---\n\n")
+
+	fmt.Fprintf(&b, "**Category:** %s · **Points:** %d", c.Category, w.Points)
+	if w.SolvedAt != nil {
+		fmt.Fprintf(&b, " · **Solved:** %s", w.SolvedAt.UTC().Format("2006-01-02 15:04 UTC"))
+	}
+	if len(w.SolvedBy) > 0 {
+		fmt.Fprintf(&b, " by %s", strings.Join(w.SolvedBy, ", "))
+	}
+	b.WriteString("\n\n## Description\n\n")
+	if desc := jeopardy.ParseDescription(c.Description).Markdown; desc != "" {
+		for _, line := range strings.Split(desc, "\n") {
+			b.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+		b.WriteString("\n")
+	}
+	if len(c.Endpoints) > 0 {
+		b.WriteString("```\n")
+		for _, ep := range c.Endpoints {
+			fmt.Fprintf(&b, "%s\n", ep.Command())
+		}
+		b.WriteString("```\n\n")
+	}
+	if len(c.Files) > 0 {
+		b.WriteString("## Attachments\n\n")
+		for _, f := range c.Files {
+			fmt.Fprintf(&b, "- `%s`\n", f.Name())
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("## Solution\n\n<!-- TODO: explain the solution -->\n\n")
+	b.WriteString("```\nFLAG{...}\n```\n")
+	return b.String()
+}
+
+func renderWriteupIndex(writeups []*writeup, format string) string {
+	var b strings.Builder
+	b.WriteString("---\ntitle: \"Writeups\"\n")
+	if format == "jekyll" {
+		b.WriteString("layout: page\n")
+	}
+	b.WriteString("---\n")
+
+	category := "\x00"
+	for _, w := range writeups {
+		if cat := w.Challenge.Category; !strings.EqualFold(cat, category) {
+			category = cat
+			fmt.Fprintf(&b, "\n## %s\n\n", nonEmptyString(cat, "Uncategorized"))
+		}
+		link := strings.TrimSuffix(w.File, ".md") + "/"
+		if format == "jekyll" {
+			// Jekyll serves posts at their permalink, not their file name.
+			link = "{% post_url " + strings.TrimSuffix(w.File, ".md") + " %}"
+		}
+		fmt.Fprintf(&b, "- [%s](%s) (%d points)\n", w.Challenge.Name, link, w.Points)
+	}
+	return b.String()
+}
+
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = strconv.Quote(v)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,6 @@
+from os import urandom
+
+flag = open("flag.txt", "rb").read()
+key = urandom(len(flag))
+print(bytes(a ^ b for a, b in zip(flag, key)).hex())
+print(key[::-1].hex())
@@ -1,68 +1,120 @@
 #!/usr/bin/env python3
-"""example ctf-sync script backend - reads json from stdin, writes json to stdout"""
+"""example ctf-sync script backend - reads json from stdin, writes json to stdout
+
+run with --persistent (and the persistent setting) to stay up and answer
+newline-delimited json-rpc requests instead of one request per process.
+"""
 
 import json
 import sys
 
+PROTOCOL = 2
 
-def main():
-    req = json.load(sys.stdin)
+
+class ScriptError(Exception):
+    """reported as {"error": {"kind": ..., "message": ...}}; kind is one of
+    auth, rate_limited, not_found, or anything else"""
+
+    def __init__(self, message, kind=""):
+        super().__init__(message)
+        self.kind = kind
+
+
+def handle(req):
     action = req.get("action")
+    # every setting other than the backend's own, e.g. -S base_url=...
+    settings = req.get("settings", {})
+    base_url = settings.get("base_url", "https://example.com")
+
+    if action == "hello":
+        return {"protocol": PROTOCOL, "actions": ["fetch", "submit", "solves", "download"]}
 
     if action == "fetch":
-        print(
-            json.dumps(
+        return {
+            "challenges": [
                 {
-                    "challenges": [
-                        {
-                            "id": "1",
-                            "name": "sanity check",
-                            "category": "misc",
-                            "description": "flag is FLAG{hello}",
-                            "points": 50,
-                            "files": [],
-                        },
+                    "id": "1",
+                    "name": "sanity check",
+                    "category": "misc",
+                    "description": "flag is FLAG{hello}",
+                    "points": 50,
+                    # no url: handed over by the download action below
+                    "files": [{"name": "notes.txt"}],
+                    "tags": ["warmup"],
+                    "solved": True,
+                },
+                {
+                    "id": "2",
+                    "name": "baby web",
+                    "category": "web",
+                    "description": "look at the source",
+                    "points": 100,
+                    "hints": ["ctrl+u"],
+                    "connection_info": base_url,
+                    "files": [
                         {
-                            "id": "2",
-                            "name": "baby web",
-                            "category": "web",
-                            "description": "look at the source",
-                            "points": 100,
-                            "files": [
-                                {
-                                    "name": "index.html",
-                                    "url": "https://example.com/index.html",
-                                }
-                            ],
-                        },
-                    ]
-                }
-            )
-        )
-
-    elif action == "submit":
+                            "name": "index.html",
+                            "url": f"{base_url}/index.html",
+                        }
+                    ],
+                },
+            ]
+        }
+
+    if action == "submit":
         challenge_id = req.get("challenge_id")
         flag = req.get("flag")
 
         correct_flags = {"1": "FLAG{hello}", "2": "FLAG{view_source}"}
 
         if challenge_id not in correct_flags:
-            print(json.dumps({"status": "error", "message": "unknown challenge"}))
-        elif flag == correct_flags[challenge_id]:
-            print(json.dumps({"status": "accepted", "message": "correct!"}))
-        else:
-            print(json.dumps({"status": "rejected", "message": "wrong flag"}))
-
-    elif action == "solves":
-        print(
-            json.dumps(
-                {"solves": [{"challenge_id": "1", "solved_at": "2025-01-01T12:00:00Z"}]}
-            )
-        )
-
-    else:
-        print(json.dumps({"error": f"unknown action: {action}"}), file=sys.stderr)
-        sys.exit(1)
+            return {"status": "error", "message": "unknown challenge"}
+        if flag == correct_flags[challenge_id]:
+            return {"status": "accepted", "message": "correct!"}
+        return {"status": "rejected", "message": "wrong flag"}
+
+    if action == "solves":
+        return {"solves": [{"challenge_id": "1", "solved_at": "2025-01-01T12:00:00Z"}]}
+
+    if action == "download":
+        if req.get("file") != "notes.txt":
+            raise ScriptError(f"unknown file: {req.get('file')}", "not_found")
+        # write it where asked; printing {"data": <base64>} chunks works too
+        with open(req["path"], "w") as f:
+            f.write("the flag is in the description\n")
+        return {}
+
+    if action == "ping":
+        return {}
+
+    raise ScriptError(f"unknown action: {action}")
+
+
+def serve():
+    # log in once here; every request below reuses the session
+    for line in sys.stdin:
+        if not line.strip():
+            continue
+        req = json.loads(line)
+        resp = {"jsonrpc": "2.0", "id": req["id"]}
+        try:
+            resp["result"] = handle(req["params"])
+        except ScriptError as e:
+            resp["error"] = {"code": -32000, "message": str(e), "data": {"kind": e.kind}}
+        print(json.dumps(resp), flush=True)
+
+
+def main():
+    if sys.argv[1:] == ["--persistent"]:
+        serve()
+        return
+
+    # anything on stderr shows up in ctf-sync's log; prefix lines with
+    # "warning:" or "error:" to pick the level
+    try:
+        print(json.dumps(handle(json.load(sys.stdin))))
+    except ScriptError as e:
+        print(json.dumps({"error": {"kind": e.kind, "message": str(e)}}))
 
 
 if __name__ == "__main__":
@@ -1,3 +1,10 @@
 module github.com/rw-r-r-0644/ctf-sync
 
 go 1.23.0
+
+require (
+	github.com/ulikunitz/xz v0.5.12
+	golang.org/x/net v0.35.0
+	golang.org/x/text v0.22.0
+	gopkg.in/yaml.v3 v3.0.1
+)
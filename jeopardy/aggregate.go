@@ -0,0 +1,230 @@
+package jeopardy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+func init() {
+	Register(BackendDef{
+		ID:   "aggregate",
+		Name: "Several CTFs at once",
+		Settings: []SettingDef{
+			{ID: "profiles", Name: "Comma-separated profile names; each needs <name>.backend and its settings as <name>.<setting>", Required: true},
+		},
+		Build: buildAggregate,
+	})
+}
+
+// AggregateMember is one backend of an Aggregate. Name prefixes its
+// challenge IDs, so it must be unique and can't contain ':'.
+type AggregateMember struct {
+	Name    string
+	Backend Backend
+}
+
+// Aggregate merges several backends into one. Challenge IDs are
+// namespaced as "name:id" and submissions are routed by that prefix.
+//
+// When some members fail and others don't, Fetch and Solves return the
+// results they have along with a *PartialError naming the failures.
+type Aggregate struct {
+	members []AggregateMember
+}
+
+// PartialError reports the members of an Aggregate that failed while
+// others answered.
+type PartialError struct {
+	// Failed maps member names to their errors.
+	Failed map[string]error
+}
+
+func (e *PartialError) Error() string {
+	names := make([]string, 0, len(e.Failed))
+	for name := range e.Failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + ": " + e.Failed[name].Error()
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e *PartialError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, err := range e.Failed {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// NewAggregate returns an Aggregate over members, listed in their order.
+func NewAggregate(members ...AggregateMember) (*Aggregate, error) {
+	seen := make(map[string]bool)
+	for _, m := range members {
+		if m.Name == "" || strings.Contains(m.Name, ":") {
+			return nil, fmt.Errorf("invalid aggregate member name %q", m.Name)
+		}
+		if seen[m.Name] {
+			return nil, fmt.Errorf("duplicate aggregate member %q", m.Name)
+		}
+		seen[m.Name] = true
+	}
+	return &Aggregate{members: slices.Clone(members)}, nil
+}
+
+// Close closes every member.
+func (a *Aggregate) Close() error {
+	var errs []error
+	for _, m := range a.members {
+		if err := Close(m.Backend); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// buildAggregate builds each profile's backend from the settings prefixed
+// with its name.
+func buildAggregate(s map[string]string) (Backend, error) {
+	var members []AggregateMember
+	for _, name := range strings.Split(s["profiles"], ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		settings := make(map[string]string)
+		for k, v := range s {
+			if key, ok := strings.CutPrefix(k, name+"."); ok {
+				settings[key] = v
+			}
+		}
+		backendID := settings["backend"]
+		if backendID == "" {
+			return nil, fmt.Errorf("profile %s: %s.backend is required", name, name)
+		}
+		delete(settings, "backend")
+		b, err := Build(backendID, settings)
+		if err != nil {
+			closeMembers(members)
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		members = append(members, AggregateMember{Name: name, Backend: b})
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("no profiles to aggregate")
+	}
+	a, err := NewAggregate(members...)
+	if err != nil {
+		closeMembers(members)
+		return nil, err
+	}
+	return a, nil
+}
+
+// closeMembers closes the backends already built when building an
+// Aggregate fails.
+func closeMembers(members []AggregateMember) {
+	for _, m := range members {
+		Close(m.Backend)
+	}
+}
+
+// each calls fn for every member concurrently. When only some fail it
+// returns a *PartialError, when all do their errors joined.
+func (a *Aggregate) each(fn func(i int, m AggregateMember) error) error {
+	errs := make([]error, len(a.members))
+	var wg sync.WaitGroup
+	for i, m := range a.members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(i, m)
+		}()
+	}
+	wg.Wait()
+
+	failed := make(map[string]error)
+	var all []error
+	for i, err := range errs {
+		if err != nil {
+			failed[a.members[i].Name] = err
+			all = append(all, fmt.Errorf("%s: %w", a.members[i].Name, err))
+		}
+	}
+	switch len(failed) {
+	case 0:
+		return nil
+	case len(a.members):
+		return errors.Join(all...)
+	}
+	return &PartialError{Failed: failed}
+}
+
+func (a *Aggregate) Fetch(ctx context.Context) ([]Challenge, error) {
+	results := make([][]Challenge, len(a.members))
+	err := a.each(func(i int, m AggregateMember) error {
+		challenges, err := m.Backend.Fetch(ctx)
+		challenges = slices.Clone(challenges)
+		for j := range challenges {
+			challenges[j].ID = m.Name + ":" + challenges[j].ID
+		}
+		results[i] = challenges
+		return err
+	})
+	if err != nil && !isPartial(err) {
+		return nil, err
+	}
+	return slices.Concat(results...), err
+}
+
+func (a *Aggregate) Submit(ctx context.Context, challengeID, flag string) (*SubmitResult, error) {
+	m, id, err := a.route(challengeID)
+	if err != nil {
+		return nil, err
+	}
+	return m.Backend.Submit(ctx, id, flag)
+}
+
+func (a *Aggregate) Solves(ctx context.Context) ([]Solve, error) {
+	results := make([][]Solve, len(a.members))
+	err := a.each(func(i int, m AggregateMember) error {
+		solves, err := m.Backend.Solves(ctx)
+		solves = slices.Clone(solves)
+		for j := range solves {
+			solves[j].ChallengeID = m.Name + ":" + solves[j].ChallengeID
+		}
+		results[i] = solves
+		return err
+	})
+	if err != nil && !isPartial(err) {
+		return nil, err
+	}
+	return slices.Concat(results...), err
+}
+
+// route finds the member a namespaced challenge ID belongs to.
+func (a *Aggregate) route(challengeID string) (AggregateMember, string, error) {
+	name, id, ok := strings.Cut(challengeID, ":")
+	if !ok {
+		return AggregateMember{}, "", fmt.Errorf("challenge id %q has no profile prefix (want profile:id)", challengeID)
+	}
+	for _, m := range a.members {
+		if m.Name == name {
+			return m, id, nil
+		}
+	}
+	return AggregateMember{}, "", fmt.Errorf("unknown profile %q in challenge id %q", name, challengeID)
+}
+
+func isPartial(err error) bool {
+	var partial *PartialError
+	return errors.As(err, &partial)
+}
@@ -0,0 +1,103 @@
+package jeopardy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAggregate(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "a.yaml"), "challenges:\n  - name: warmup\n    flag_sha256: "+flagHash("flag{a}")+"\n")
+	writeTestFile(t, filepath.Join(dir, "b.yaml"), "challenges:\n  - name: sanity\n  - name: xorrox\n")
+
+	b, err := Build("aggregate", map[string]string{
+		"profiles":  "a, b",
+		"a.backend": "local",
+		"a.path":    filepath.Join(dir, "a.yaml"),
+		"b.backend": "local",
+		"b.path":    filepath.Join(dir, "b.yaml"),
+		"b.solves":  filepath.Join(dir, "b-solves.json"),
+		"c.backend": "ignored",
+	})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	ctx := context.Background()
+	challenges, err := b.Fetch(ctx)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	var ids []string
+	for _, c := range challenges {
+		ids = append(ids, c.ID)
+	}
+	if len(ids) != 3 || ids[0] != "a:1" || ids[1] != "b:1" || ids[2] != "b:2" {
+		t.Fatalf("ids = %q", ids)
+	}
+
+	res, err := b.Submit(ctx, "a:1", "flag{a}")
+	if err != nil || res.Status != Accepted {
+		t.Fatalf("Submit = %+v, %v", res, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b-solves.json")); err == nil {
+		t.Errorf("submission reached the wrong profile")
+	}
+	for _, id := range []string{"1", "z:1"} {
+		if _, err := b.Submit(ctx, id, "flag{a}"); err == nil {
+			t.Errorf("Submit(%q) succeeded", id)
+		}
+	}
+
+	solves, err := b.Solves(ctx)
+	if err != nil || len(solves) != 1 || solves[0].ChallengeID != "a:1" {
+		t.Errorf("Solves = %+v, %v", solves, err)
+	}
+
+	if _, err := Build("aggregate", map[string]string{"profiles": "a"}); err == nil {
+		t.Error("Build accepted a profile without a backend")
+	}
+}
+
+func TestAggregatePartialFailure(t *testing.T) {
+	down := errors.New("connection refused")
+	a, err := NewAggregate(
+		AggregateMember{Name: "up", Backend: &countingBackend{challenges: []Challenge{{ID: "1"}}}},
+		AggregateMember{Name: "down", Backend: &countingBackend{err: down}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		challenges, err := a.Fetch(ctx)
+		var partial *PartialError
+		if !errors.As(err, &partial) || partial.Failed["down"] != down || !errors.Is(err, down) {
+			t.Fatalf("Fetch error = %v, want a PartialError for down", err)
+		}
+		if len(challenges) != 1 || challenges[0].ID != "up:1" {
+			t.Fatalf("challenges = %+v", challenges)
+		}
+	}
+
+	// Partial results go through the cache without being cached.
+	c := &Cache{Backend: a, Path: filepath.Join(t.TempDir(), "cache.json")}
+	if challenges, err := c.Fetch(ctx); len(challenges) != 1 || !isPartial(err) {
+		t.Errorf("cached Fetch = %+v, %v", challenges, err)
+	}
+	if _, err := os.Stat(c.Path); err == nil {
+		t.Errorf("partial results were cached")
+	}
+
+	all, _ := NewAggregate(AggregateMember{Name: "down", Backend: &countingBackend{err: down}})
+	if challenges, err := all.Fetch(ctx); challenges != nil || !errors.Is(err, down) || isPartial(err) {
+		t.Errorf("Fetch with every member down = %+v, %v", challenges, err)
+	}
+
+	if _, err := NewAggregate(AggregateMember{Name: "a:b"}); err == nil {
+		t.Error("NewAggregate accepted a name with ':'")
+	}
+}
@@ -1,8 +1,13 @@
 package jeopardy
 
-import "context"
+import (
+	"context"
+	"io"
+)
 
 // Backend is the interface for jeopardy-style CTF platform integrations.
+// Backends that hold resources, such as a script's process, also implement
+// io.Closer; see Close.
 type Backend interface {
 	// Fetch retrieves all challenges from the platform.
 	Fetch(ctx context.Context) ([]Challenge, error)
@@ -14,3 +19,12 @@ type Backend interface {
 	// Returns empty slice if not supported by the platform.
 	Solves(ctx context.Context) ([]Solve, error)
 }
+
+// Close closes b if it implements io.Closer. Middlewares, Cache and
+// Aggregate pass Close on to the backends they wrap.
+func Close(b Backend) error {
+	if c, ok := b.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+package jeopardy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrOffline is returned by an offline Cache for calls it can't answer
+// from disk.
+var ErrOffline = errors.New("offline and not cached")
+
+// ErrNotModified is returned by ConditionalFetcher when the challenge list
+// hasn't changed.
+var ErrNotModified = errors.New("not modified")
+
+// Validators identify one version of a challenge list, for revalidation.
+type Validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// ConditionalFetcher is implemented by backends that can tell an unchanged
+// challenge list apart cheaply. FetchIfChanged returns ErrNotModified, along
+// with the validators to keep, when nothing changed since v.
+type ConditionalFetcher interface {
+	FetchIfChanged(ctx context.Context, v Validators) ([]Challenge, Validators, error)
+}
+
+// Cache is a Backend that keeps the last successful Fetch and Solves of
+// another Backend in a JSON file.
+//
+// Results younger than TTL are served from the file. Older ones are
+// revalidated through ConditionalFetcher when the backend implements it, and
+// fetched again otherwise. If the platform is down, the stale results are
+// served and the error is passed to Warn. In Offline mode the backend is
+// never contacted, so cached files can't be downloaded either.
+type Cache struct {
+	Backend Backend
+	Path    string
+	TTL     time.Duration
+	Offline bool
+	// Warn, if set, receives errors that were worked around: stale results
+	// served after a failure, or a cache file that couldn't be written.
+	Warn func(error)
+
+	mu sync.Mutex
+
+	liveMu sync.Mutex
+	live   map[string][]File // challenge ID
+}
+
+type cacheState struct {
+	Challenges *cachedChallenges `json:"challenges,omitempty"`
+	Solves     *cachedSolves     `json:"solves,omitempty"`
+}
+
+type cachedChallenges struct {
+	FetchedAt  time.Time         `json:"fetched_at"`
+	Validators Validators        `json:"validators"`
+	Challenges []cachedChallenge `json:"items"`
+}
+
+type cachedSolves struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	Solves    []Solve   `json:"items"`
+}
+
+// cachedChallenge stores only file names, in order; Files are rebuilt as
+// cachedFile, which resolves the live file when downloaded.
+type cachedChallenge struct {
+	Challenge
+	Files []string
+}
+
+func (c *Cache) Fetch(ctx context.Context) ([]Challenge, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.load()
+	cached := state.Challenges
+	if cached != nil && (c.Offline || time.Since(cached.FetchedAt) < c.TTL) {
+		return c.restore(cached.Challenges), nil
+	}
+	if c.Offline {
+		return nil, ErrOffline
+	}
+
+	var v Validators
+	if cached != nil {
+		v = cached.Validators
+	}
+	challenges, v, err := fetchIfChanged(ctx, c.Backend, v)
+	switch {
+	case isPartial(err):
+		// Part of an Aggregate failed: pass on what there is, but don't
+		// cache an incomplete list.
+		return challenges, err
+	case errors.Is(err, ErrNotModified) && cached != nil:
+		cached.FetchedAt = time.Now()
+		cached.Validators = v
+		c.save(state)
+		return c.restore(cached.Challenges), nil
+	case err != nil && cached != nil:
+		c.warn(fmt.Errorf("serving cached challenges from %s: %w", cached.FetchedAt.Format(time.DateTime), err))
+		return c.restore(cached.Challenges), nil
+	case err != nil:
+		return nil, err
+	}
+
+	c.setLive(challenges)
+	state.Challenges = &cachedChallenges{FetchedAt: time.Now(), Validators: v}
+	for _, ch := range challenges {
+		cc := cachedChallenge{Challenge: ch}
+		for _, f := range ch.Files {
+			cc.Files = append(cc.Files, f.Name())
+		}
+		cc.Challenge.Files = nil
+		state.Challenges.Challenges = append(state.Challenges.Challenges, cc)
+	}
+	c.save(state)
+	return challenges, nil
+}
+
+// Submit always goes to the backend. A correct flag marks the cached
+// challenge solved and drops the cached solves.
+func (c *Cache) Submit(ctx context.Context, challengeID, flag string) (*SubmitResult, error) {
+	if c.Offline {
+		return nil, ErrOffline
+	}
+	result, err := c.Backend.Submit(ctx, challengeID, flag)
+	if err != nil || (result.Status != Accepted && result.Status != Duplicate) {
+		return result, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state := c.load()
+	if state.Challenges != nil {
+		for i := range state.Challenges.Challenges {
+			if state.Challenges.Challenges[i].ID == challengeID {
+				state.Challenges.Challenges[i].Solved = true
+			}
+		}
+	}
+	state.Solves = nil
+	c.save(state)
+	return result, nil
+}
+
+func (c *Cache) Solves(ctx context.Context) ([]Solve, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.load()
+	cached := state.Solves
+	if cached != nil && (c.Offline || time.Since(cached.FetchedAt) < c.TTL) {
+		return cached.Solves, nil
+	}
+	if c.Offline {
+		return nil, ErrOffline
+	}
+
+	solves, err := c.Backend.Solves(ctx)
+	if isPartial(err) {
+		return solves, err
+	}
+	if err != nil {
+		if cached != nil {
+			c.warn(fmt.Errorf("serving cached solves from %s: %w", cached.FetchedAt.Format(time.DateTime), err))
+			return cached.Solves, nil
+		}
+		return nil, err
+	}
+	state.Solves = &cachedSolves{FetchedAt: time.Now(), Solves: solves}
+	c.save(state)
+	return solves, nil
+}
+
+// load reads the cache file. A missing or unreadable file is an empty cache.
+func (c *Cache) load() *cacheState {
+	var state cacheState
+	data, err := os.ReadFile(c.Path)
+	if err != nil {
+		return &state
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		c.warn(fmt.Errorf("ignoring corrupt cache %s: %w", c.Path, err))
+		return &cacheState{}
+	}
+	return &state
+}
+
+// save writes the cache file atomically, so an interrupted write never
+// leaves a truncated cache behind.
+func (c *Cache) save(state *cacheState) {
+	data, err := json.Marshal(state)
+	if err == nil {
+		err = writeFileAtomic(c.Path, data)
+	}
+	if err != nil {
+		c.warn(fmt.Errorf("write cache: %w", err))
+	}
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *Cache) warn(err error) {
+	if c.Warn != nil {
+		c.Warn(err)
+	}
+}
+
+func (c *Cache) restore(cached []cachedChallenge) []Challenge {
+	challenges := make([]Challenge, 0, len(cached))
+	for _, cc := range cached {
+		ch := cc.Challenge
+		ch.Files = nil
+		seen := make(map[string]int)
+		for _, name := range cc.Files {
+			ch.Files = append(ch.Files, &cachedFile{name: name, nth: seen[name], challengeID: ch.ID, cache: c})
+			seen[name]++
+		}
+		challenges = append(challenges, ch)
+	}
+	return challenges
+}
+
+func (c *Cache) setLive(challenges []Challenge) {
+	c.liveMu.Lock()
+	defer c.liveMu.Unlock()
+	c.live = indexFiles(challenges)
+}
+
+func indexFiles(challenges []Challenge) map[string][]File {
+	index := make(map[string][]File, len(challenges))
+	for _, ch := range challenges {
+		index[ch.ID] = ch.Files
+	}
+	return index
+}
+
+// liveFile finds the backend's file behind a cached one, the nth file
+// named name, fetching from the backend once for all files of this Cache.
+// Attachments may share a name.
+func (c *Cache) liveFile(ctx context.Context, challengeID, name string, nth int) (File, error) {
+	c.liveMu.Lock()
+	defer c.liveMu.Unlock()
+	if c.live == nil {
+		if c.Offline {
+			return nil, ErrOffline
+		}
+		challenges, err := c.Backend.Fetch(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("resolve cached file: %w", err)
+		}
+		c.live = indexFiles(challenges)
+	}
+	for _, f := range c.live[challengeID] {
+		if f.Name() != name {
+			continue
+		}
+		if nth == 0 {
+			return f, nil
+		}
+		nth--
+	}
+	return nil, fmt.Errorf("file %s no longer listed for challenge %s", name, challengeID)
+}
+
+// cachedFile is a File restored from the cache. Downloading it resolves the
+// current file from the backend, since download URLs and tokens expire.
+type cachedFile struct {
+	name        string
+	nth         int // among the challenge's files with this name
+	challengeID string
+	cache       *Cache
+}
+
+func (f *cachedFile) Name() string { return f.name }
+
+func (f *cachedFile) DownloadURL(ctx context.Context) (*DownloadInfo, error) {
+	live, err := f.cache.liveFile(ctx, f.challengeID, f.name, f.nth)
+	if err != nil {
+		return nil, err
+	}
+	return live.DownloadURL(ctx)
+}
+
+func (f *cachedFile) openRange(ctx context.Context, offset int64, ifRange string) (*http.Response, error) {
+	live, err := f.cache.liveFile(ctx, f.challengeID, f.name, f.nth)
+	if err != nil {
+		return nil, err
+	}
+	switch o := live.(type) {
+	case rangeOpener:
+		return o.openRange(ctx, offset, ifRange)
+	case Opener:
+		// No range support: hand back the whole file, as a server
+		// ignoring Range would.
+		body, err := o.Open(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: body, ContentLength: -1}, nil
+	}
+
+	info, err := live.DownloadURL(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get download url: %w", err)
+	}
+	resp, err := startDownload(ctx, newDownloadClient(nil), info, offset, ifRange)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDownloadStatus(resp, offset); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,218 @@
+package jeopardy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// countingBackend serves fixed challenges and counts calls.
+type countingBackend struct {
+	challenges []Challenge
+	err        error
+	fetches    int
+	submits    int
+}
+
+func (b *countingBackend) Fetch(ctx context.Context) ([]Challenge, error) {
+	b.fetches++
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.challenges, nil
+}
+
+func (b *countingBackend) Submit(ctx context.Context, challengeID, flag string) (*SubmitResult, error) {
+	b.submits++
+	return &SubmitResult{Status: Accepted}, nil
+}
+
+func (b *countingBackend) Solves(ctx context.Context) ([]Solve, error) {
+	return nil, b.err
+}
+
+func TestCacheTTLAndOffline(t *testing.T) {
+	inner := &countingBackend{challenges: []Challenge{{ID: "1", Name: "warmup", Points: 50}}}
+	path := filepath.Join(t.TempDir(), "cache.json")
+	c := &Cache{Backend: inner, Path: path, TTL: time.Hour}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		got, err := c.Fetch(ctx)
+		if err != nil {
+			t.Fatalf("Fetch failed: %v", err)
+		}
+		if len(got) != 1 || got[0].Name != "warmup" {
+			t.Fatalf("unexpected challenges: %+v", got)
+		}
+	}
+	if inner.fetches != 1 {
+		t.Errorf("backend fetched %d times, want 1", inner.fetches)
+	}
+
+	// A fresh Cache on the same file, offline, never reaches the backend.
+	inner.err = errors.New("should not be called")
+	offline := &Cache{Backend: inner, Path: path, Offline: true}
+	got, err := offline.Fetch(ctx)
+	if err != nil || len(got) != 1 {
+		t.Fatalf("offline Fetch = %v, %v", got, err)
+	}
+	if _, err := offline.Solves(ctx); !errors.Is(err, ErrOffline) {
+		t.Errorf("offline Solves error = %v, want ErrOffline", err)
+	}
+	if inner.fetches != 1 {
+		t.Errorf("backend fetched %d times, want 1", inner.fetches)
+	}
+}
+
+func TestCacheServesStaleOnError(t *testing.T) {
+	inner := &countingBackend{challenges: []Challenge{{ID: "1", Name: "warmup"}}}
+	c := &Cache{Backend: inner, Path: filepath.Join(t.TempDir(), "cache.json")}
+	ctx := context.Background()
+	if _, err := c.Fetch(ctx); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+
+	inner.err = errors.New("502 bad gateway")
+	var warned error
+	c.Warn = func(err error) { warned = err }
+	got, err := c.Fetch(ctx)
+	if err != nil || len(got) != 1 {
+		t.Fatalf("stale Fetch = %v, %v", got, err)
+	}
+	if warned == nil {
+		t.Error("expected a warning for stale data")
+	}
+}
+
+func TestCachedFileResolvesLiveFile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "contents")
+	}))
+	defer srv.Close()
+
+	inner := &countingBackend{challenges: []Challenge{{
+		ID:    "1",
+		Files: []File{&urlFile{name: "handout.txt", url: srv.URL}},
+	}}}
+	path := filepath.Join(t.TempDir(), "cache.json")
+	ctx := context.Background()
+	if _, err := (&Cache{Backend: inner, Path: path, TTL: time.Hour}).Fetch(ctx); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+
+	c := &Cache{Backend: inner, Path: path, TTL: time.Hour}
+	got, err := c.Fetch(ctx)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if _, ok := got[0].Files[0].(*cachedFile); !ok {
+		t.Fatalf("file is %T, want a cached file", got[0].Files[0])
+	}
+	r, err := OpenFile(ctx, got[0].Files[0])
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer r.Close()
+	data, _ := io.ReadAll(r)
+	if string(data) != "contents" {
+		t.Errorf("got %q", data)
+	}
+}
+
+func TestCachedFilesShareName(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	inner := &countingBackend{challenges: []Challenge{{
+		ID: "1",
+		Files: []File{
+			&urlFile{name: "handout.txt", url: srv.URL + "/first"},
+			&urlFile{name: "handout.txt", url: srv.URL + "/second"},
+		},
+	}}}
+	path := filepath.Join(t.TempDir(), "cache.json")
+	ctx := context.Background()
+	if _, err := (&Cache{Backend: inner, Path: path, TTL: time.Hour}).Fetch(ctx); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+
+	got, err := (&Cache{Backend: inner, Path: path, TTL: time.Hour}).Fetch(ctx)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	for i, want := range []string{"/first", "/second"} {
+		r, err := OpenFile(ctx, got[0].Files[i])
+		if err != nil {
+			t.Fatalf("OpenFile failed: %v", err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		if string(data) != want {
+			t.Errorf("file %d = %q, want %q", i, data, want)
+		}
+	}
+}
+
+func TestCTFdFetchIfChanged(t *testing.T) {
+	details := 0
+	etag := ""
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/challenges", func(w http.ResponseWriter, r *http.Request) {
+		if etag != "" && r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		fmt.Fprint(w, `{"success": true, "data": [{"id": 1, "name": "warmup", "category": "misc", "value": 50}]}`)
+	})
+	mux.HandleFunc("/api/v1/challenges/1", func(w http.ResponseWriter, r *http.Request) {
+		details++
+		fmt.Fprint(w, `{"success": true, "data": {"id": 1, "name": "warmup", "value": 50}}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c, err := newCTFd(srv.URL, tokenAuth("test-token"), "")
+	if err != nil {
+		t.Fatalf("newCTFd failed: %v", err)
+	}
+	ctx := context.Background()
+
+	challenges, v, err := c.FetchIfChanged(ctx, Validators{})
+	if err != nil || len(challenges) != 1 {
+		t.Fatalf("FetchIfChanged = %v, %v", challenges, err)
+	}
+	// Without validators the same list is no proof the details didn't
+	// change: they are fetched again.
+	if _, v, err = c.FetchIfChanged(ctx, v); err != nil {
+		t.Fatalf("FetchIfChanged failed: %v", err)
+	}
+	if details != 2 {
+		t.Errorf("got %d detail requests, want 2", details)
+	}
+
+	etag = `"v1"`
+	if _, v, err = c.FetchIfChanged(ctx, Validators{}); err != nil {
+		t.Fatalf("FetchIfChanged failed: %v", err)
+	}
+	if v.ETag != etag {
+		t.Errorf("ETag = %q, want %q", v.ETag, etag)
+	}
+	if _, _, err = c.FetchIfChanged(ctx, Validators{ETag: v.ETag}); !errors.Is(err, ErrNotModified) {
+		t.Errorf("error = %v, want ErrNotModified on 304", err)
+	}
+	if details != 3 {
+		t.Errorf("got %d detail requests, want 3", details)
+	}
+}
@@ -76,7 +76,7 @@ func newCCIT(baseURL, token, version string) (*ccitClient, error) {
 		baseURL: strings.TrimRight(baseURL, "/"),
 		token:   token,
 		version: version,
-		client:  &http.Client{Timeout: 30 * time.Second},
+		client:  &http.Client{Timeout: 30 * time.Second, Transport: defaultTransport},
 	}, nil
 }
 
@@ -104,13 +104,13 @@ func (c *ccitClient) Fetch(ctx context.Context) ([]Challenge, error) {
 				}
 
 				chal := Challenge{
-					ID:          detail.ID.String(),
-					Name:        detail.Title,
-					Category:    section.Name, // Using section as category seems map to structure
-					Description: detail.Description,
-					Points:      detail.Points,
-					Solved:      detail.Solved,
+					ID:       detail.ID.String(),
+					Name:     detail.Title,
+					Category: section.Name, // Using section as category seems map to structure
+					Points:   detail.Points,
+					Solved:   detail.Solved,
 				}
+				chal.SetDescription(detail.Description)
 
 				if len(detail.Tags) > 0 {
 					chal.Tags = make([]string, len(detail.Tags))
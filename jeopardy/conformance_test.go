@@ -0,0 +1,64 @@
+package jeopardy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy"
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy/jeopardytest"
+)
+
+func TestConformance(t *testing.T) {
+	cases := []struct {
+		backend  string
+		handler  func(*jeopardytest.Platform) http.Handler
+		settings map[string]string
+		statuses []jeopardy.SubmitStatus
+	}{
+		{
+			backend:  "ctfd_token",
+			handler:  jeopardytest.CTFdHandler,
+			settings: map[string]string{"token": "ctfd_x"},
+			statuses: []jeopardy.SubmitStatus{jeopardy.Duplicate, jeopardy.RateLimited, jeopardy.Pending, jeopardy.Error},
+		},
+		{
+			backend:  "ctfd_cookie",
+			handler:  jeopardytest.CTFdHandler,
+			settings: map[string]string{"cookie": "session=x"},
+			statuses: []jeopardy.SubmitStatus{jeopardy.Duplicate, jeopardy.RateLimited, jeopardy.Pending, jeopardy.Error},
+		},
+		{
+			backend:  "rctf",
+			handler:  jeopardytest.RCTFHandler,
+			settings: map[string]string{"team_token": "x"},
+			statuses: []jeopardy.SubmitStatus{jeopardy.Duplicate, jeopardy.RateLimited, jeopardy.Error},
+		},
+		{
+			backend:  "ccit",
+			handler:  jeopardytest.CCITHandler,
+			settings: map[string]string{"token": "x", "x-version": "v5.0.2"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.backend, func(t *testing.T) {
+			jeopardytest.Run(t, jeopardytest.Harness{
+				New: func(t *testing.T, p *jeopardytest.Platform) jeopardy.Backend {
+					srv := httptest.NewServer(tc.handler(p))
+					t.Cleanup(srv.Close)
+					settings := map[string]string{"base_url": srv.URL}
+					for k, v := range tc.settings {
+						settings[k] = v
+					}
+					b, err := jeopardy.Build(tc.backend, settings)
+					if err != nil {
+						t.Fatalf("Build failed: %v", err)
+					}
+					return b
+				},
+				Statuses: tc.statuses,
+			})
+		})
+	}
+}
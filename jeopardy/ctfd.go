@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -22,9 +24,10 @@ func init() {
 		Settings: []SettingDef{
 			{ID: "base_url", Name: "Base URL", Required: true},
 			{ID: "token", Name: "API Token", Required: true},
+			{ID: "mode", Name: "User Mode (users or teams, detected if empty)"},
 		},
 		Build: func(s map[string]string) (Backend, error) {
-			return newCTFd(s["base_url"], tokenAuth(s["token"]))
+			return newCTFd(s["base_url"], tokenAuth(s["token"]), s["mode"])
 		},
 	})
 
@@ -34,33 +37,127 @@ func init() {
 		Settings: []SettingDef{
 			{ID: "base_url", Name: "Base URL", Required: true},
 			{ID: "cookie", Name: "Session Cookie", Required: true},
+			{ID: "mode", Name: "User Mode (users or teams, detected if empty)"},
 		},
 		Build: func(s map[string]string) (Backend, error) {
-			return newCTFd(s["base_url"], cookieAuth(s["cookie"]))
+			return newCTFd(s["base_url"], cookieAuth(s["cookie"]), s["mode"])
 		},
 	})
 }
 
+// CTFd user modes, as reported by the user_mode config.
+const (
+	ctfdUsersMode = "users"
+	ctfdTeamsMode = "teams"
+)
+
 type ctfdClient struct {
 	baseURL   string
 	applyAuth func(*http.Request)
 	client    *http.Client
 	authType  string
+
+	modeMu   sync.Mutex
+	userMode string
+}
+
+// ctfdStatusError is returned by doRequest for non-200 responses.
+type ctfdStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *ctfdStatusError) Error() string {
+	return fmt.Sprintf("request failed status=%d: %s", e.StatusCode, e.Body)
 }
 
 type ctfdFile struct {
-	name   string
-	path   string
-	client *ctfdClient
+	name        string
+	path        string
+	challengeID int
+	client      *ctfdClient
+
+	mu sync.Mutex
 }
 
 func (f *ctfdFile) Name() string { return f.name }
 
 func (f *ctfdFile) DownloadURL(ctx context.Context) (*DownloadInfo, error) {
-	return &DownloadInfo{
-		URL:     f.client.resolveFileURL(f.path),
-		Headers: f.client.authHeaders(),
-	}, nil
+	f.mu.Lock()
+	fileURL := f.client.resolveFileURL(f.path)
+	f.mu.Unlock()
+
+	info := &DownloadInfo{URL: fileURL}
+	if f.client.isOwnURL(fileURL) {
+		info.Headers = f.client.authHeaders()
+	}
+	return info, nil
+}
+
+// Open downloads the file. CTFd signs file URLs with a ?token= that expires,
+// so a 403 triggers one refresh of the challenge detail before giving up.
+func (f *ctfdFile) Open(ctx context.Context) (io.ReadCloser, error) {
+	resp, err := f.openRange(ctx, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (f *ctfdFile) openRange(ctx context.Context, offset int64, ifRange string) (*http.Response, error) {
+	resp, err := f.start(ctx, offset, ifRange)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusForbidden {
+		resp.Body.Close()
+		if err := f.refresh(ctx); err != nil {
+			return nil, fmt.Errorf("refresh file token: %w", err)
+		}
+		if resp, err = f.start(ctx, offset, ifRange); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkDownloadStatus(resp, offset); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (f *ctfdFile) start(ctx context.Context, offset int64, ifRange string) (*http.Response, error) {
+	info, err := f.DownloadURL(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return startDownload(ctx, newDownloadClient(f.client.client.Transport), info, offset, ifRange)
+}
+
+// refresh re-fetches the challenge detail and picks up the freshly signed
+// path of the same file, matched on its path without the token query:
+// attachments in different upload directories may share a name.
+func (f *ctfdFile) refresh(ctx context.Context) error {
+	detail, err := f.client.fetchDetail(ctx, f.challengeID)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	want := stripQuery(f.path)
+	for _, fileRef := range detail.Files {
+		if stripQuery(fileRef) == want {
+			f.path = fileRef
+			return nil
+		}
+	}
+	return fmt.Errorf("file %s no longer listed in challenge %d", f.name, f.challengeID)
+}
+
+// stripQuery drops the query and fragment of a file path or URL.
+func stripQuery(ref string) string {
+	if i := strings.IndexAny(ref, "?#"); i >= 0 {
+		return ref[:i]
+	}
+	return ref
 }
 
 func tokenAuth(token string) func(*http.Request) {
@@ -75,22 +172,37 @@ func cookieAuth(cookie string) func(*http.Request) {
 	}
 }
 
-func newCTFd(baseURL string, auth func(*http.Request)) (*ctfdClient, error) {
+func newCTFd(baseURL string, auth func(*http.Request), mode string) (*ctfdClient, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "", ctfdUsersMode, ctfdTeamsMode:
+	default:
+		return nil, fmt.Errorf("invalid mode %q: must be %q or %q", mode, ctfdUsersMode, ctfdTeamsMode)
+	}
+
 	authType := "token"
 	return &ctfdClient{
 		baseURL:   strings.TrimRight(baseURL, "/"),
 		applyAuth: auth,
-		client:    &http.Client{Timeout: 30 * time.Second},
+		client:    &http.Client{Timeout: 30 * time.Second, Transport: defaultTransport},
 		authType:  authType,
+		userMode:  mode,
 	}, nil
 }
 
 func (c *ctfdClient) doRequest(ctx context.Context, method, path string, body any, out any) error {
+	_, err := c.doRequestHeader(ctx, method, path, nil, body, out)
+	return err
+}
+
+// doRequestHeader is doRequest with extra request headers, returning the
+// response headers.
+func (c *ctfdClient) doRequestHeader(ctx context.Context, method, path string, header http.Header, body any, out any) (http.Header, error) {
 	var bodyReader io.Reader
 	if body != nil {
 		data, err := json.Marshal(body)
 		if err != nil {
-			return fmt.Errorf("marshal body: %w", err)
+			return nil, fmt.Errorf("marshal body: %w", err)
 		}
 		bodyReader = bytes.NewBuffer(data)
 	}
@@ -98,7 +210,10 @@ func (c *ctfdClient) doRequest(ctx context.Context, method, path string, body an
 	reqURL := c.baseURL + path
 	req, err := http.NewRequestWithContext(ctx, method, reqURL, bodyReader)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
 	}
 	c.applyAuth(req)
 	req.Header.Set("Accept", "application/json")
@@ -114,55 +229,127 @@ func (c *ctfdClient) doRequest(ctx context.Context, method, path string, body an
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("read response body: %w", err)
+		return nil, fmt.Errorf("read response body: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("request failed status=%d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+		return resp.Header, &ctfdStatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(respBody))}
 	}
 
 	if out != nil {
 		if err := json.Unmarshal(respBody, out); err != nil {
-			return fmt.Errorf("decode response from %s: %w", path, err)
+			return nil, fmt.Errorf("decode response from %s: %w", path, err)
 		}
 	}
-	return nil
+	return resp.Header, nil
+}
+
+// ctfdFetchAll fetches every page of a CTFd list endpoint, following
+// meta.pagination.next until the last page. Unpaginated responses are
+// returned as a single page.
+func ctfdFetchAll[T any](ctx context.Context, c *ctfdClient, path string) ([]T, error) {
+	all, _, err := ctfdFetchAllHeader[T](ctx, c, path, nil)
+	return all, err
+}
+
+// ctfdFetchAllHeader is ctfdFetchAll sending header with the first page
+// request and returning that page's response headers.
+func ctfdFetchAllHeader[T any](ctx context.Context, c *ctfdClient, path string, header http.Header) ([]T, http.Header, error) {
+	var all []T
+	var firstHeader http.Header
+	page := 0
+	for {
+		reqPath := path
+		var reqHeader http.Header
+		if page > 0 {
+			reqPath = withQueryParam(path, "page", strconv.Itoa(page))
+		} else {
+			reqHeader = header
+		}
+
+		var parsed ctfdListResponse[T]
+		respHeader, err := c.doRequestHeader(ctx, "GET", reqPath, reqHeader, nil, &parsed)
+		if err != nil {
+			return nil, respHeader, err
+		}
+		if firstHeader == nil {
+			firstHeader = respHeader
+		}
+		if !parsed.Success {
+			return nil, firstHeader, fmt.Errorf("api error: %s", nonEmpty(parsed.Message, "success=false"))
+		}
+		all = append(all, parsed.Data...)
+
+		// Stop unless next moves forward, so a misbehaving server can't loop us forever.
+		next := parsed.Meta.Pagination.Next
+		if next == nil || *next <= page || *next <= parsed.Meta.Pagination.Page {
+			return all, firstHeader, nil
+		}
+		page = *next
+	}
 }
 
 func (c *ctfdClient) Fetch(ctx context.Context) ([]Challenge, error) {
-	var listResp ctfdListResponse
-	if err := c.doRequest(ctx, "GET", "/api/v1/challenges", nil, &listResp); err != nil {
-		return nil, err
+	summaries, err := ctfdFetchAll[ctfdChallengeSummary](ctx, c, "/api/v1/challenges")
+	if err != nil {
+		return nil, fmt.Errorf("fetch challenges: %w", err)
 	}
-	if !listResp.Success {
-		return nil, fmt.Errorf("fetch challenges failed: success=false")
+	return c.fetchChallenges(ctx, summaries)
+}
+
+// FetchIfChanged implements ConditionalFetcher. The challenge list is
+// requested conditionally, and only a 304 counts as unchanged: the list
+// leaves out descriptions, files, connection info and hints, so an
+// identical list says nothing about them.
+func (c *ctfdClient) FetchIfChanged(ctx context.Context, v Validators) ([]Challenge, Validators, error) {
+	header := make(http.Header)
+	if v.ETag != "" {
+		header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		header.Set("If-Modified-Since", v.LastModified)
 	}
 
-	results := make([]Challenge, 0, len(listResp.Data))
-	for _, summary := range listResp.Data {
-		var detailResp ctfdDetailResponse
-		path := fmt.Sprintf("/api/v1/challenges/%d", summary.ID)
-		if err := c.doRequest(ctx, "GET", path, nil, &detailResp); err != nil {
+	summaries, respHeader, err := ctfdFetchAllHeader[ctfdChallengeSummary](ctx, c, "/api/v1/challenges", header)
+	var statusErr *ctfdStatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotModified {
+		return nil, v, ErrNotModified
+	}
+	if err != nil {
+		return nil, v, fmt.Errorf("fetch challenges: %w", err)
+	}
+
+	next := Validators{
+		ETag:         respHeader.Get("ETag"),
+		LastModified: respHeader.Get("Last-Modified"),
+	}
+	challenges, err := c.fetchChallenges(ctx, summaries)
+	return challenges, next, err
+}
+
+func (c *ctfdClient) fetchChallenges(ctx context.Context, summaries []ctfdChallengeSummary) ([]Challenge, error) {
+	results := make([]Challenge, 0, len(summaries))
+	for _, summary := range summaries {
+		detail, err := c.fetchDetail(ctx, summary.ID)
+		if err != nil {
 			return nil, err
 		}
-		if !detailResp.Success {
-			return nil, fmt.Errorf("fetch detail %d failed: success=false", summary.ID)
-		}
-		detail := detailResp.Data
 
 		challenge := Challenge{
-			ID:          strconv.Itoa(summary.ID),
-			Name:        nonEmpty(detail.Name, summary.Name),
-			Category:    nonEmpty(detail.Category, summary.Category),
-			Description: detail.Description,
-			Points:      detail.Value,
+			ID:       strconv.Itoa(summary.ID),
+			Name:     nonEmpty(detail.Name, summary.Name),
+			Category: nonEmpty(detail.Category, summary.Category),
+			Points:   detail.Value,
+			Solved:   summary.SolvedByMe,
 		}
+		challenge.SetDescription(detail.Description)
+		challenge.Endpoints = MergeEndpoints(ParseEndpoints(detail.ConnectionInfo), challenge.Endpoints)
 
 		if len(detail.Files) > 0 {
 			challenge.Files = make([]File, 0, len(detail.Files))
@@ -171,9 +358,10 @@ func (c *ctfdClient) Fetch(ctx context.Context) ([]Challenge, error) {
 					continue
 				}
 				challenge.Files = append(challenge.Files, &ctfdFile{
-					name:   filenameFromURL(fileRef),
-					path:   fileRef,
-					client: c,
+					name:        filenameFromURL(fileRef),
+					path:        fileRef,
+					challengeID: summary.ID,
+					client:      c,
 				})
 			}
 		}
@@ -182,6 +370,18 @@ func (c *ctfdClient) Fetch(ctx context.Context) ([]Challenge, error) {
 	return results, nil
 }
 
+func (c *ctfdClient) fetchDetail(ctx context.Context, id int) (*ctfdChallengeDetail, error) {
+	var detailResp ctfdDetailResponse
+	path := fmt.Sprintf("/api/v1/challenges/%d", id)
+	if err := c.doRequest(ctx, "GET", path, nil, &detailResp); err != nil {
+		return nil, err
+	}
+	if !detailResp.Success {
+		return nil, fmt.Errorf("fetch detail %d failed: success=false", id)
+	}
+	return &detailResp.Data, nil
+}
+
 func (c *ctfdClient) Submit(ctx context.Context, challengeID, flag string) (*SubmitResult, error) {
 	if flag == "" {
 		return nil, fmt.Errorf("flag is required")
@@ -202,48 +402,99 @@ func (c *ctfdClient) Submit(ctx context.Context, challengeID, flag string) (*Sub
 
 	var parsed ctfdSubmitResponse
 	if err := c.doRequest(ctx, "POST", "/api/v1/challenges/attempt", payload, &parsed); err != nil {
-		return nil, err
+		// CTFd answers rate limits (429) and paused CTFs (403) with a
+		// regular attempt response.
+		var statusErr *ctfdStatusError
+		if !errors.As(err, &statusErr) || json.Unmarshal([]byte(statusErr.Body), &parsed) != nil || parsed.Data.Status == "" {
+			return nil, err
+		}
 	}
 
 	return c.parseSubmitResponse(parsed), nil
 }
 
 func (c *ctfdClient) Solves(ctx context.Context) ([]Solve, error) {
-	endpoints := []string{
-		"/api/v1/users/me/solves",
-		"/api/v1/teams/me/solves",
+	mode, err := c.mode(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	var lastErr error
-	for _, path := range endpoints {
-		var parsed ctfdSolvesResponse
-		if err := c.doRequest(ctx, "GET", path, nil, &parsed); err != nil {
-			lastErr = err
+	// In team mode the team endpoint is the only one that includes teammates' solves.
+	path := "/api/v1/users/me/solves"
+	if mode == ctfdTeamsMode {
+		path = "/api/v1/teams/me/solves"
+	}
+
+	entries, err := ctfdFetchAll[ctfdSolveEntry](ctx, c, path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Solve, 0, len(entries))
+	for _, entry := range entries {
+		if entry.ChallengeID == 0 {
 			continue
 		}
-		if !parsed.Success {
-			lastErr = fmt.Errorf("api error: %s", parsed.Message)
-			continue
+		solve := Solve{
+			ChallengeID: strconv.Itoa(entry.ChallengeID),
+			Points:      entry.Challenge.Value,
 		}
+		if t := parseCTFdSolveTime(entry.Date); t != nil {
+			solve.SolvedAt = t
+		}
+		if entry.User.ID != 0 {
+			solve.UserID = strconv.Itoa(entry.User.ID)
+			solve.UserName = entry.User.Name
+		}
+		result = append(result, solve)
+	}
+	return result, nil
+}
 
-		result := make([]Solve, 0, len(parsed.Data))
-		for _, entry := range parsed.Data {
-			if entry.ChallengeID == 0 {
-				continue
-			}
-			solve := Solve{ChallengeID: strconv.Itoa(entry.ChallengeID)}
-			if t := parseCTFdSolveTime(entry.Date); t != nil {
-				solve.SolvedAt = t
-			}
-			result = append(result, solve)
+// mode returns the instance's user mode, detecting and caching it on first use
+// unless it was set explicitly.
+func (c *ctfdClient) mode(ctx context.Context) (string, error) {
+	c.modeMu.Lock()
+	defer c.modeMu.Unlock()
+
+	if c.userMode != "" {
+		return c.userMode, nil
+	}
+	mode, err := c.detectMode(ctx)
+	if err != nil {
+		return "", fmt.Errorf("detect ctfd user mode: %w", err)
+	}
+	c.userMode = mode
+	return mode, nil
+}
+
+func (c *ctfdClient) detectMode(ctx context.Context) (string, error) {
+	// The config endpoint is usually admin-only, so failures here are expected.
+	var configResp ctfdConfigResponse
+	if err := c.doRequest(ctx, "GET", "/api/v1/configs/user_mode", nil, &configResp); err == nil && configResp.Success {
+		switch mode := strings.ToLower(configResp.Data.Value); mode {
+		case ctfdUsersMode, ctfdTeamsMode:
+			return mode, nil
 		}
-		return result, nil
 	}
 
-	if lastErr != nil {
-		return nil, lastErr
+	// /teams/me only exists in team mode; user mode answers 404.
+	// A 403 means team mode but the account hasn't joined a team yet.
+	var teamResp ctfdTeamResponse
+	err := c.doRequest(ctx, "GET", "/api/v1/teams/me", nil, &teamResp)
+	if err == nil {
+		return ctfdTeamsMode, nil
+	}
+	var statusErr *ctfdStatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusNotFound:
+			return ctfdUsersMode, nil
+		case http.StatusForbidden:
+			return ctfdTeamsMode, nil
+		}
 	}
-	return nil, fmt.Errorf("ctfd solves request failed")
+	return "", err
 }
 
 func (c *ctfdClient) fetchCSRFToken(ctx context.Context) (string, error) {
@@ -286,6 +537,20 @@ func (c *ctfdClient) resolveFileURL(fileRef string) string {
 	return c.baseURL + "/" + strings.TrimLeft(fileRef, "/")
 }
 
+// isOwnURL reports whether rawURL points at the CTFd instance itself,
+// i.e. whether it may receive our credentials.
+func (c *ctfdClient) isOwnURL(rawURL string) bool {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return false
+	}
+	return sameOrigin(target, base)
+}
+
 func (c *ctfdClient) authHeaders() map[string]string {
 	headers := map[string]string{}
 	req := &http.Request{Header: make(http.Header)}
@@ -332,24 +597,38 @@ func (c *ctfdClient) parseSubmitResponse(parsed ctfdSubmitResponse) *SubmitResul
 }
 
 type ctfdChallengeSummary struct {
-	ID       int    `json:"id"`
-	Name     string `json:"name"`
-	Category string `json:"category"`
-	Value    int    `json:"value"`
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Category   string `json:"category"`
+	Value      int    `json:"value"`
+	SolvedByMe bool   `json:"solved_by_me"`
 }
 
 type ctfdChallengeDetail struct {
-	ID          int      `json:"id"`
-	Name        string   `json:"name"`
-	Category    string   `json:"category"`
-	Description string   `json:"description"`
-	Value       int      `json:"value"`
-	Files       []string `json:"files"`
+	ID             int      `json:"id"`
+	Name           string   `json:"name"`
+	Category       string   `json:"category"`
+	Description    string   `json:"description"`
+	ConnectionInfo string   `json:"connection_info"`
+	Value          int      `json:"value"`
+	Files          []string `json:"files"`
+}
+
+type ctfdListResponse[T any] struct {
+	Success bool   `json:"success"`
+	Data    []T    `json:"data"`
+	Message string `json:"message"`
+	Meta    struct {
+		Pagination ctfdPagination `json:"pagination"`
+	} `json:"meta"`
 }
 
-type ctfdListResponse struct {
-	Success bool                   `json:"success"`
-	Data    []ctfdChallengeSummary `json:"data"`
+type ctfdPagination struct {
+	Page    int  `json:"page"`
+	Next    *int `json:"next"`
+	Pages   int  `json:"pages"`
+	PerPage int  `json:"per_page"`
+	Total   int  `json:"total"`
 }
 
 type ctfdDetailResponse struct {
@@ -369,12 +648,28 @@ type ctfdSubmitResponse struct {
 type ctfdSolveEntry struct {
 	ChallengeID int    `json:"challenge_id"`
 	Date        string `json:"date"`
+	Challenge   struct {
+		Value int `json:"value"`
+	} `json:"challenge"`
+	User struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"user"`
+}
+
+type ctfdConfigResponse struct {
+	Success bool `json:"success"`
+	Data    struct {
+		Value string `json:"value"`
+	} `json:"data"`
 }
 
-type ctfdSolvesResponse struct {
-	Success bool             `json:"success"`
-	Data    []ctfdSolveEntry `json:"data"`
-	Message string           `json:"message"`
+type ctfdTeamResponse struct {
+	Success bool `json:"success"`
+	Data    struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"data"`
 }
 
 func nonEmpty(values ...string) string {
@@ -386,6 +681,14 @@ func nonEmpty(values ...string) string {
 	return ""
 }
 
+func withQueryParam(rawPath, key, value string) string {
+	sep := "?"
+	if strings.Contains(rawPath, "?") {
+		sep = "&"
+	}
+	return rawPath + sep + url.QueryEscape(key) + "=" + url.QueryEscape(value)
+}
+
 func filenameFromURL(raw string) string {
 	parsed, err := url.Parse(raw)
 	if err == nil && parsed.Path != "" {
@@ -0,0 +1,172 @@
+package jeopardy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// pagedHandler serves items as CTFd-style pages of perPage entries.
+func pagedHandler(t *testing.T, items []string, perPage int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			var err error
+			if page, err = strconv.Atoi(p); err != nil {
+				t.Errorf("bad page %q", p)
+			}
+		}
+		pages := (len(items) + perPage - 1) / perPage
+		start := (page - 1) * perPage
+		end := min(start+perPage, len(items))
+
+		data := "["
+		for i := start; i < end; i++ {
+			if i > start {
+				data += ","
+			}
+			data += items[i]
+		}
+		data += "]"
+
+		next := "null"
+		if page < pages {
+			next = strconv.Itoa(page + 1)
+		}
+		fmt.Fprintf(w, `{"success": true, "data": %s, "meta": {"pagination": {"page": %d, "next": %s, "pages": %d, "per_page": %d, "total": %d}}}`,
+			data, page, next, pages, perPage, len(items))
+	}
+}
+
+func TestCTFdFetchPaginated(t *testing.T) {
+	var summaries []string
+	for i := 1; i <= 5; i++ {
+		summaries = append(summaries, fmt.Sprintf(`{"id": %d, "name": "chall %d", "category": "misc", "value": 100}`, i, i))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/challenges", pagedHandler(t, summaries, 2))
+	mux.HandleFunc("/api/v1/challenges/{id}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"success": true, "data": {"id": %s, "description": "desc", "value": 100}}`, r.PathValue("id"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c, err := newCTFd(srv.URL, tokenAuth("test-token"), "")
+	if err != nil {
+		t.Fatalf("newCTFd failed: %v", err)
+	}
+	challenges, err := c.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if len(challenges) != 5 {
+		t.Fatalf("got %d challenges, want 5", len(challenges))
+	}
+	for i, ch := range challenges {
+		if want := strconv.Itoa(i + 1); ch.ID != want {
+			t.Errorf("challenge %d has ID %q, want %q", i, ch.ID, want)
+		}
+	}
+}
+
+func TestCTFdSolvesPaginated(t *testing.T) {
+	var entries []string
+	for i := 1; i <= 7; i++ {
+		entries = append(entries, fmt.Sprintf(`{"challenge_id": %d, "date": "2025-01-01T12:00:00Z"}`, i))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/users/me/solves", pagedHandler(t, entries, 3))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c, err := newCTFd(srv.URL, tokenAuth("test-token"), ctfdUsersMode)
+	if err != nil {
+		t.Fatalf("newCTFd failed: %v", err)
+	}
+	solves, err := c.Solves(context.Background())
+	if err != nil {
+		t.Fatalf("Solves failed: %v", err)
+	}
+	if len(solves) != 7 {
+		t.Fatalf("got %d solves, want 7", len(solves))
+	}
+}
+
+func TestCTFdFetchAllStopsOnStuckPagination(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `{"success": true, "data": [1], "meta": {"pagination": {"page": 1, "next": 1}}}`)
+	}))
+	defer srv.Close()
+
+	c, err := newCTFd(srv.URL, tokenAuth("test-token"), "")
+	if err != nil {
+		t.Fatalf("newCTFd failed: %v", err)
+	}
+	items, err := ctfdFetchAll[int](context.Background(), c, "/api/v1/things")
+	if err != nil {
+		t.Fatalf("ctfdFetchAll failed: %v", err)
+	}
+	if len(items) != 1 || requests != 1 {
+		t.Errorf("got %d items in %d requests, want 1 in 1", len(items), requests)
+	}
+}
+
+func TestCTFdFileOpenRefreshesToken(t *testing.T) {
+	var storageAuth string
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		storageAuth = r.Header.Get("Authorization")
+		fmt.Fprint(w, "file contents")
+	}))
+	defer storage.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/challenges/1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"success": true, "data": {"id": 1, "files": ["/files/xyz/handout.zip?token=other", "/files/abc/handout.zip?token=fresh"]}}`)
+	})
+	mux.HandleFunc("/files/xyz/handout.zip", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("refresh picked the other file named handout.zip")
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/files/abc/handout.zip", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token test-token" {
+			t.Errorf("missing auth on same-origin file request")
+		}
+		if r.URL.Query().Get("token") != "fresh" {
+			http.Error(w, "token expired", http.StatusForbidden)
+			return
+		}
+		http.Redirect(w, r, storage.URL+"/bucket/handout.zip?X-Amz-Signature=sig", http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c, err := newCTFd(srv.URL, tokenAuth("test-token"), "")
+	if err != nil {
+		t.Fatalf("newCTFd failed: %v", err)
+	}
+	f := &ctfdFile{name: "handout.zip", path: "/files/abc/handout.zip?token=stale", challengeID: 1, client: c}
+
+	body, err := f.Open(context.Background())
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	if string(data) != "file contents" {
+		t.Errorf("got %q", data)
+	}
+	if storageAuth != "" {
+		t.Errorf("Authorization leaked to cross-origin redirect: %q", storageAuth)
+	}
+}
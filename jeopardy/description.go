@@ -0,0 +1,337 @@
+package jeopardy
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Link is a hyperlink found in a challenge description.
+type Link struct {
+	Text string
+	URL  string
+}
+
+// Description is a challenge description normalized to Markdown, with the
+// links and endpoints found in it.
+type Description struct {
+	Markdown  string
+	Links     []Link
+	FileLinks []string
+	Endpoints []Endpoint
+	// Connections are the nc/ssh/telnet commands among Endpoints, as
+	// written.
+	Connections []string
+}
+
+// ParseDescription normalizes a raw description, whether HTML (CCIT, some
+// CTFd themes) or Markdown (rCTF, CTFd), into Markdown and extracts links,
+// links to files and endpoints from it.
+func ParseDescription(raw string) Description {
+	md := raw
+	if looksLikeHTML(raw) {
+		md = htmlToMarkdown(raw)
+	} else {
+		md = cleanMarkdown(md)
+	}
+
+	d := Description{Markdown: md}
+	d.Links = extractLinks(md)
+	for _, l := range d.Links {
+		if isFileLink(l.URL) {
+			d.FileLinks = append(d.FileLinks, l.URL)
+		}
+	}
+	d.Endpoints = ParseEndpoints(md)
+	d.Connections = connectionCommands(d.Endpoints)
+	return d
+}
+
+// SetDescription sets the raw description and fills the fields extracted
+// from it. Backends call it so every platform gets the same extraction.
+func (c *Challenge) SetDescription(raw string) {
+	d := ParseDescription(raw)
+	c.Description = raw
+	c.Links = d.Links
+	c.FileLinks = d.FileLinks
+	c.Connections = d.Connections
+	c.Endpoints = d.Endpoints
+}
+
+// connectionCommands returns the text of the endpoints that aren't web
+// services.
+func connectionCommands(eps []Endpoint) []string {
+	var cmds []string
+	for _, ep := range eps {
+		if ep.Protocol != ProtocolHTTP && ep.Protocol != ProtocolHTTPS {
+			cmds = append(cmds, ep.Raw)
+		}
+	}
+	return cmds
+}
+
+var blockTagRe = regexp.MustCompile(`(?i)<(p|div|br|ul|ol|li|h[1-6]|pre|table|blockquote|hr)\b[^>]*>`)
+
+// looksLikeHTML reports whether raw uses block-level HTML. Markdown with the
+// odd inline tag is left alone, since it renders fine as Markdown.
+func looksLikeHTML(raw string) bool {
+	return blockTagRe.MatchString(raw)
+}
+
+var (
+	blankLinesRe = regexp.MustCompile(`\n{3,}`)
+	spaceRunRe   = regexp.MustCompile(`[ \t\r\n\f]+`)
+)
+
+// cleanMarkdown normalizes line endings, trims trailing whitespace (keeping
+// two-space hard breaks) and collapses runs of blank lines.
+func cleanMarkdown(md string) string {
+	md = strings.ReplaceAll(md, "\r\n", "\n")
+	lines := strings.Split(md, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimRight(line, " \t")
+		if trimmed != "" && strings.HasSuffix(line, "  ") {
+			trimmed += "  "
+		}
+		lines[i] = trimmed
+	}
+	md = strings.Join(lines, "\n")
+	md = blankLinesRe.ReplaceAllString(md, "\n\n")
+	return strings.Trim(md, "\n")
+}
+
+func htmlToMarkdown(raw string) string {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(raw), body)
+	if err != nil {
+		return cleanMarkdown(raw)
+	}
+	var b strings.Builder
+	for _, n := range nodes {
+		b.WriteString(renderNode(n))
+	}
+	return cleanMarkdown(b.String())
+}
+
+// renderNode converts an HTML node to Markdown. Block elements are
+// surrounded by blank lines, which cleanMarkdown later collapses.
+func renderNode(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return spaceRunRe.ReplaceAllString(n.Data, " ")
+	case html.ElementNode:
+	default:
+		return renderChildren(n)
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Head:
+		return ""
+	case atom.Br:
+		return "  \n"
+	case atom.Hr:
+		return "\n\n---\n\n"
+	case atom.P, atom.Div, atom.Section, atom.Article:
+		return "\n\n" + trimLines(renderChildren(n)) + "\n\n"
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		return "\n\n" + strings.Repeat("#", level) + " " + strings.TrimSpace(renderChildren(n)) + "\n\n"
+	case atom.Strong, atom.B:
+		return wrapInline(renderChildren(n), "**")
+	case atom.Em, atom.I:
+		return wrapInline(renderChildren(n), "*")
+	case atom.Code:
+		return wrapInline(textContent(n), "`")
+	case atom.Pre:
+		return "\n\n```\n" + strings.Trim(textContent(n), "\n") + "\n```\n\n"
+	case atom.A:
+		text := strings.TrimSpace(renderChildren(n))
+		href := attr(n, "href")
+		if href == "" {
+			return text
+		}
+		if text == "" {
+			text = href
+		}
+		return "[" + text + "](" + href + ")"
+	case atom.Img:
+		return "![" + attr(n, "alt") + "](" + attr(n, "src") + ")"
+	case atom.Ul, atom.Ol:
+		return "\n\n" + renderList(n) + "\n\n"
+	case atom.Blockquote:
+		inner := cleanMarkdown(renderChildren(n))
+		return "\n\n> " + strings.ReplaceAll(inner, "\n", "\n> ") + "\n\n"
+	case atom.Tr:
+		var cells []string
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.DataAtom == atom.Td || c.DataAtom == atom.Th {
+				cells = append(cells, strings.TrimSpace(renderChildren(c)))
+			}
+		}
+		return "| " + strings.Join(cells, " | ") + " |\n"
+	case atom.Table:
+		return "\n\n" + renderChildren(n) + "\n\n"
+	}
+	return renderChildren(n)
+}
+
+func renderChildren(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(renderNode(c))
+	}
+	return b.String()
+}
+
+func renderList(n *html.Node) string {
+	var items []string
+	i := 1
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.DataAtom != atom.Li {
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", i)
+			i++
+		}
+		content := blankLinesRe.ReplaceAllString(cleanMarkdown(renderChildren(c)), "\n")
+		content = strings.ReplaceAll(content, "\n\n", "\n")
+		indent := strings.Repeat(" ", len(marker))
+		items = append(items, marker+strings.ReplaceAll(strings.TrimSpace(content), "\n", "\n"+indent))
+	}
+	return strings.Join(items, "\n")
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.DataAtom == atom.Br {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// wrapInline wraps s in marker, keeping surrounding spaces outside so the
+// emphasis stays valid Markdown.
+func wrapInline(s, marker string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return s
+	}
+	lead := s[:strings.Index(s, trimmed)]
+	trail := s[len(lead)+len(trimmed):]
+	return lead + marker + trimmed + marker + trail
+}
+
+// trimLines strips the whitespace that collapsed HTML text leaves at the
+// start of lines after a <br>.
+func trimLines(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimLeft(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+var (
+	mdLinkRe   = regexp.MustCompile(`!?\[([^\]]*)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	hrefRe     = regexp.MustCompile(`(?i)<a\s[^>]*href=["']([^"']+)["'][^>]*>([^<]*)`)
+	bareURLRe  = regexp.MustCompile("https?://[^\\s<>()\\[\\]\"'`]+")
+	connLineRe = regexp.MustCompile(`(?m)\b((?:nc|ncat|netcat|telnet|ssh|openssl\s+s_client)\s[^\n` + "`" + `]+)`)
+)
+
+func extractLinks(md string) []Link {
+	var links []Link
+	seen := make(map[string]bool)
+	add := func(text, u string) {
+		u = strings.TrimRight(u, ".,;:!?")
+		if u == "" || seen[u] {
+			return
+		}
+		seen[u] = true
+		links = append(links, Link{Text: text, URL: u})
+	}
+
+	for _, m := range mdLinkRe.FindAllStringSubmatch(md, -1) {
+		add(m[1], m[2])
+	}
+	for _, m := range hrefRe.FindAllStringSubmatch(md, -1) {
+		add(strings.TrimSpace(m[2]), m[1])
+	}
+	// Bare URLs, minus the ones already found as link targets.
+	stripped := mdLinkRe.ReplaceAllString(md, "")
+	for _, u := range bareURLRe.FindAllString(stripped, -1) {
+		add("", u)
+	}
+	return links
+}
+
+// fileExtensions are link targets treated as downloadable attachments.
+var fileExtensions = map[string]bool{
+	".zip": true, ".tar": true, ".gz": true, ".tgz": true, ".xz": true, ".bz2": true, ".7z": true, ".rar": true,
+	".py": true, ".c": true, ".cpp": true, ".rs": true, ".go": true, ".js": true, ".sage": true, ".txt": true,
+	".pcap": true, ".pcapng": true, ".bin": true, ".elf": true, ".exe": true, ".so": true, ".img": true,
+	".pdf": true, ".png": true, ".jpg": true, ".wav": true, ".apk": true, ".jar": true, ".dmp": true,
+}
+
+func isFileLink(u string) bool {
+	p := u
+	if i := strings.IndexAny(p, "?#"); i >= 0 {
+		p = p[:i]
+	}
+	if strings.Contains(p, "/files/") {
+		return true
+	}
+	return fileExtensions[strings.ToLower(path.Ext(p))]
+}
+
+// hostPortRe and sshTargetRe check that a candidate command really names
+// a host, so prose like "nc is useful" doesn't count.
+var (
+	hostPortRe   = regexp.MustCompile(`^(?:nc|ncat|netcat|telnet)(?:\s+-[\w-]+)*\s+(?:[\w-]+(?:\.[\w-]+)+|localhost)\s+\d{1,5}\b`)
+	sClientCmdRe = regexp.MustCompile(`^openssl\s+s_client(?:\s+-\w+(?:\s+[^\s-]\S*)?)*?\s+-connect\s+(?:[\w-]+(?:\.[\w-]+)+|localhost):\d{1,5}\b`)
+	sshRe        = regexp.MustCompile(`^ssh(?:\s+-[a-zA-Z](?:\s*[^\s-]\S*)?)*\s+(?:[\w.-]+@)?(?:[\w-]+(?:\.[\w-]+)+|localhost)(?:\s+-p\s*\d+)?`)
+)
+
+// extractConnections finds the nc/ssh/telnet/openssl s_client commands that ParseEndpoints
+// turns into endpoints.
+func extractConnections(md string) []string {
+	var conns []string
+	seen := make(map[string]bool)
+	for _, m := range connLineRe.FindAllStringSubmatch(md, -1) {
+		cmd := strings.TrimSpace(m[1])
+		match := hostPortRe.FindString(cmd)
+		if strings.HasPrefix(cmd, "ssh") {
+			match = sshRe.FindString(cmd)
+		} else if strings.HasPrefix(cmd, "openssl") {
+			match = sClientCmdRe.FindString(cmd)
+		}
+		match = strings.TrimRight(match, ".")
+		if match == "" || seen[match] {
+			continue
+		}
+		seen[match] = true
+		conns = append(conns, match)
+	}
+	return conns
+}
@@ -0,0 +1,64 @@
+package jeopardy
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseDescriptionHTML(t *testing.T) {
+	raw := `<p>Can you <b>pwn</b> this?<br>
+  Connect with <code>nc chall.example.com 1337</code></p>
+<ul><li>hint: <a href="https://example.com/docs">read the docs</a></li><li>second</li></ul>
+<p>Handout: <a href="/files/abc123/handout.zip?token=x">handout.zip</a></p>
+<script>alert(1)</script>`
+
+	d := ParseDescription(raw)
+	want := "Can you **pwn** this?  \nConnect with `nc chall.example.com 1337`\n\n" +
+		"- hint: [read the docs](https://example.com/docs)\n- second\n\n" +
+		"Handout: [handout.zip](/files/abc123/handout.zip?token=x)"
+	if d.Markdown != want {
+		t.Errorf("Markdown =\n%s\nwant\n%s", d.Markdown, want)
+	}
+	if len(d.Links) != 2 || d.Links[0].Text != "read the docs" {
+		t.Errorf("Links = %+v", d.Links)
+	}
+	if !slices.Equal(d.FileLinks, []string{"/files/abc123/handout.zip?token=x"}) {
+		t.Errorf("FileLinks = %v", d.FileLinks)
+	}
+	if !slices.Equal(d.Connections, []string{"nc chall.example.com 1337"}) {
+		t.Errorf("Connections = %v", d.Connections)
+	}
+}
+
+func TestParseDescriptionMarkdown(t *testing.T) {
+	raw := "Log in with `ssh ctf@box.example.org -p 2222` (password: ctf).\r\n\r\n\r\n" +
+		"Source at https://github.com/org/chall. Also try nc 10.0.0.5 31337.\n" +
+		"nc is a great tool, ssh into things."
+
+	d := ParseDescription(raw)
+	if strings.Contains(d.Markdown, "\r") || strings.Contains(d.Markdown, "\n\n\n") {
+		t.Errorf("Markdown not cleaned: %q", d.Markdown)
+	}
+	if len(d.Links) != 1 || d.Links[0].URL != "https://github.com/org/chall" {
+		t.Errorf("Links = %+v", d.Links)
+	}
+	want := []string{"ssh ctf@box.example.org -p 2222", "nc 10.0.0.5 31337"}
+	if !slices.Equal(d.Connections, want) {
+		t.Errorf("Connections = %q, want %q", d.Connections, want)
+	}
+}
+
+func TestRenderTerminal(t *testing.T) {
+	md := "# Title\n\nSome **bold** and `code` with [a link](https://x.io).\n\n- item\n\n```\nraw *text*\n```"
+	got := RenderTerminal(md, false)
+	want := "Title\n\nSome bold and code with a link (https://x.io).\n\n  • item\n\n    raw *text*"
+	if got != want {
+		t.Errorf("RenderTerminal =\n%q\nwant\n%q", got, want)
+	}
+
+	colored := RenderTerminal("**bold**", true)
+	if colored != ansiBold+"bold"+ansiReset {
+		t.Errorf("colored = %q", colored)
+	}
+}
@@ -0,0 +1,236 @@
+package jeopardy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+)
+
+// ErrTooLarge is returned when a file exceeds Downloader.MaxSize.
+var ErrTooLarge = errors.New("file exceeds maximum size")
+
+// Downloader saves challenge attachments to disk. Downloads run in parallel,
+// are written to a ".part" file that is resumed with an HTTP Range request
+// on the next attempt, and are hashed with sha256 as they are written. The
+// file's ETag or Last-Modified is kept in a ".part.validator" file and
+// sent as If-Range, so a handout replaced in the meantime starts over
+// instead of being spliced onto the old part.
+type Downloader struct {
+	// Concurrency is the number of files downloaded at once. Defaults to 4.
+	Concurrency int
+
+	// MaxSize is the largest file accepted, in bytes. Zero means no limit.
+	MaxSize int64
+
+	// Progress, if set, is called as data arrives. It may be called
+	// from several goroutines at once.
+	Progress func(Progress)
+}
+
+// DownloadRequest names a file and the path it should be saved to.
+type DownloadRequest struct {
+	File File
+	Path string
+}
+
+// Progress reports the state of a single download.
+type Progress struct {
+	Name       string
+	Path       string
+	Downloaded int64
+	Total      int64 // -1 if unknown
+	Done       bool
+	Err        error
+}
+
+// DownloadResult is the outcome of a single download.
+type DownloadResult struct {
+	File   File
+	Path   string
+	Size   int64
+	SHA256 string
+	Err    error
+}
+
+// Download fetches every request and returns one result per request, in order.
+// Individual failures are reported in DownloadResult.Err.
+func (d *Downloader) Download(ctx context.Context, reqs []DownloadRequest) []DownloadResult {
+	results := make([]DownloadResult, len(reqs))
+	workers := d.Concurrency
+	if workers <= 0 {
+		workers = 4
+	}
+
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i] = DownloadResult{File: req.File, Path: req.Path, Err: ctx.Err()}
+				return
+			}
+			res, err := d.DownloadFile(ctx, req.File, req.Path)
+			if err != nil {
+				results[i] = DownloadResult{File: req.File, Path: req.Path, Err: err}
+				return
+			}
+			results[i] = *res
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// DownloadFile fetches a single file to path.
+func (d *Downloader) DownloadFile(ctx context.Context, f File, path string) (*DownloadResult, error) {
+	res, err := d.download(ctx, f, path)
+	d.report(Progress{Name: f.Name(), Path: path, Downloaded: res.Size, Total: res.Size, Done: true, Err: err})
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (d *Downloader) download(ctx context.Context, f File, path string) (DownloadResult, error) {
+	res := DownloadResult{File: f, Path: path}
+	partPath := path + ".part"
+	validatorPath := partPath + ".validator"
+
+	out, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return res, err
+	}
+	defer out.Close()
+
+	// Hash whatever a previous attempt left behind, so the final sum covers the whole file.
+	h := sha256.New()
+	offset, err := io.Copy(h, out)
+	if err != nil {
+		return res, fmt.Errorf("read partial download: %w", err)
+	}
+
+	ifRange := ""
+	if offset > 0 {
+		data, _ := os.ReadFile(validatorPath)
+		if ifRange = string(data); ifRange == "" {
+			// Nothing tells whether the part is from the current file.
+			if err := restart(out, h); err != nil {
+				return res, err
+			}
+			offset = 0
+		}
+	}
+
+	body, start, total, validator, err := openAt(ctx, f, offset, ifRange)
+	if err != nil && offset > 0 {
+		// Some servers reject ranges outright (a 416 for an already complete
+		// part file, for one), so retry once from scratch.
+		if err := restart(out, h); err != nil {
+			return res, err
+		}
+		offset = 0
+		body, start, total, validator, err = openAt(ctx, f, 0, "")
+	}
+	if err != nil {
+		return res, err
+	}
+	defer body.Close()
+
+	if start != offset {
+		// The server ignored or mangled the range, or the file changed
+		// and If-Range got the whole of it; start over.
+		if start != 0 {
+			return res, fmt.Errorf("server resumed at byte %d, expected %d", start, offset)
+		}
+		if err := restart(out, h); err != nil {
+			return res, err
+		}
+		offset = 0
+	}
+
+	if d.MaxSize > 0 && total > d.MaxSize {
+		os.Remove(partPath)
+		os.Remove(validatorPath)
+		return res, fmt.Errorf("%s is %d bytes: %w", f.Name(), total, ErrTooLarge)
+	}
+	if validator != ifRange {
+		if validator == "" {
+			os.Remove(validatorPath)
+		} else if err := os.WriteFile(validatorPath, []byte(validator), 0644); err != nil {
+			return res, err
+		}
+	}
+
+	w := &progressWriter{d: d, p: Progress{Name: f.Name(), Path: path, Downloaded: offset, Total: total}}
+	src := io.Reader(body)
+	if d.MaxSize > 0 {
+		// Read one byte past the limit so oversized bodies without a Content-Length are caught.
+		src = io.LimitReader(body, d.MaxSize-offset+1)
+	}
+	n, err := io.Copy(io.MultiWriter(out, h, w), src)
+	size := offset + n
+	if err != nil {
+		return res, err
+	}
+	if d.MaxSize > 0 && size > d.MaxSize {
+		out.Close()
+		os.Remove(partPath)
+		os.Remove(validatorPath)
+		return res, fmt.Errorf("%s: %w", f.Name(), ErrTooLarge)
+	}
+	if total >= 0 && size != total {
+		return res, fmt.Errorf("%s: got %d of %d bytes", f.Name(), size, total)
+	}
+
+	if err := out.Close(); err != nil {
+		return res, err
+	}
+	if err := os.Rename(partPath, path); err != nil {
+		return res, err
+	}
+	os.Remove(validatorPath)
+
+	res.Size = size
+	res.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return res, nil
+}
+
+func (d *Downloader) report(p Progress) {
+	if d.Progress != nil {
+		d.Progress(p)
+	}
+}
+
+// restart truncates a partial download and resets its hash.
+func restart(out *os.File, h hash.Hash) error {
+	if err := out.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	h.Reset()
+	return nil
+}
+
+type progressWriter struct {
+	d *Downloader
+	p Progress
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	w.p.Downloaded += int64(len(b))
+	w.d.report(w.p)
+	return len(b), nil
+}
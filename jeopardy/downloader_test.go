@@ -0,0 +1,167 @@
+package jeopardy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// urlFile is a File served from a plain URL.
+type urlFile struct {
+	name string
+	url  string
+}
+
+func (f *urlFile) Name() string { return f.name }
+
+func (f *urlFile) DownloadURL(ctx context.Context) (*DownloadInfo, error) {
+	return &DownloadInfo{URL: f.url}, nil
+}
+
+func TestDownloaderResume(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+	etag := `"v1"`
+	var gotRange, gotIfRange string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRange, gotIfRange = r.Header.Get("Range"), r.Header.Get("If-Range")
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "file.bin", time.Time{}, strings.NewReader(content))
+	}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name      string
+		validator string // left by the earlier attempt, "" for none
+		content   string // served now
+		wantRange string
+	}{
+		{"unchanged", `"v1"`, content, "bytes=4000-"},
+		{"replaced", `"v0"`, strings.Repeat("abcdefghij", 1000), "bytes=4000-"},
+		{"no validator", "", content, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			content = tc.content
+			path := filepath.Join(t.TempDir(), "file.bin")
+			if err := os.WriteFile(path+".part", []byte(strings.Repeat("0123456789", 400)), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tc.validator != "" {
+				if err := os.WriteFile(path+".part.validator", []byte(tc.validator), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			d := &Downloader{}
+			res, err := d.DownloadFile(context.Background(), &urlFile{name: "file.bin", url: srv.URL}, path)
+			if err != nil {
+				t.Fatalf("DownloadFile failed: %v", err)
+			}
+			if gotRange != tc.wantRange || (tc.wantRange != "" && gotIfRange != tc.validator) {
+				t.Errorf("Range = %q, If-Range = %q; want %q and %q", gotRange, gotIfRange, tc.wantRange, tc.validator)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tc.content {
+				t.Error("downloaded content differs")
+			}
+			sum := sha256.Sum256([]byte(tc.content))
+			if res.SHA256 != hex.EncodeToString(sum[:]) {
+				t.Errorf("sha256 = %s, want %x", res.SHA256, sum)
+			}
+			for _, leftover := range []string{".part", ".part.validator"} {
+				if _, err := os.Stat(path + leftover); !os.IsNotExist(err) {
+					t.Errorf("%s file left behind", leftover)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloaderRangeIgnored(t *testing.T) {
+	content := "full body"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path+".part", []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Downloader{}
+	if _, err := d.DownloadFile(context.Background(), &urlFile{name: "file.txt", url: srv.URL}, path); err != nil {
+		t.Fatalf("DownloadFile failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("got %q, want %q", data, content)
+	}
+}
+
+func TestDownloaderMaxSize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Flush before writing so no Content-Length is sent and the limit is enforced while streaming.
+		w.(http.Flusher).Flush()
+		w.Write([]byte(strings.Repeat("x", 2048)))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "big.bin")
+	d := &Downloader{MaxSize: 1024}
+	_, err := d.DownloadFile(context.Background(), &urlFile{name: "big.bin", url: srv.URL}, path)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("err = %v, want ErrTooLarge", err)
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Error("oversized part file left behind")
+	}
+}
+
+func TestDownloaderParallel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	var reqs []DownloadRequest
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		reqs = append(reqs, DownloadRequest{
+			File: &urlFile{name: name, url: srv.URL + "/" + name},
+			Path: filepath.Join(dir, name),
+		})
+	}
+	reqs = append(reqs, DownloadRequest{
+		File: &urlFile{name: "missing", url: srv.URL + "/missing"},
+		Path: filepath.Join(dir, "no", "such", "dir"),
+	})
+
+	d := &Downloader{Concurrency: 2}
+	results := d.Download(context.Background(), reqs)
+	for i, res := range results[:5] {
+		if res.Err != nil {
+			t.Errorf("%s: %v", reqs[i].File.Name(), res.Err)
+		}
+		if res.Path != reqs[i].Path {
+			t.Errorf("result %d out of order", i)
+		}
+	}
+	if results[5].Err == nil {
+		t.Error("expected error for unwritable path")
+	}
+}
@@ -0,0 +1,224 @@
+package jeopardy
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Protocol identifies how to talk to an Endpoint.
+type Protocol string
+
+const (
+	ProtocolTCP    Protocol = "tcp"
+	ProtocolSSH    Protocol = "ssh"
+	ProtocolTelnet Protocol = "telnet"
+	ProtocolHTTP   Protocol = "http"
+	ProtocolHTTPS  Protocol = "https"
+)
+
+// Endpoint is a network service a challenge asks players to connect to.
+type Endpoint struct {
+	Protocol Protocol
+	Host     string
+	Port     int
+	User     string
+	Password string
+	URL      string // set for http and https
+	TLS      bool   // tcp over TLS, as with ncat --ssl or openssl s_client
+	Raw      string // the text it was parsed from
+}
+
+// Address returns host:port.
+func (e Endpoint) Address() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+// Command returns the shell command that connects to the endpoint.
+func (e Endpoint) Command() string {
+	switch e.Protocol {
+	case ProtocolSSH:
+		target := e.Host
+		if e.User != "" {
+			target = e.User + "@" + e.Host
+		}
+		if e.Port != 22 {
+			return fmt.Sprintf("ssh -p %d %s", e.Port, target)
+		}
+		return "ssh " + target
+	case ProtocolTelnet:
+		return fmt.Sprintf("telnet %s %d", e.Host, e.Port)
+	case ProtocolHTTP, ProtocolHTTPS:
+		return "curl " + e.URL
+	default:
+		if e.TLS {
+			return fmt.Sprintf("ncat --ssl %s %d", e.Host, e.Port)
+		}
+		return fmt.Sprintf("nc %s %d", e.Host, e.Port)
+	}
+}
+
+var (
+	ncArgsRe       = regexp.MustCompile(`^(?:nc|ncat|netcat|telnet)((?:\s+-[\w-]+)*)\s+(\S+)\s+(\d{1,5})`)
+	ncSSLRe        = regexp.MustCompile(`\s--?ssl\b`)
+	sClientRe      = regexp.MustCompile(`\s-connect\s+(\S+):(\d{1,5})`)
+	sshPortRe      = regexp.MustCompile(`\s-p\s*(\d{1,5})`)
+	sshLoginRe     = regexp.MustCompile(`\s-l\s*(\S+)`)
+	sshOptionRe    = regexp.MustCompile(`\s-[pilFoJ]\s*\S+`)
+	sshTargetRe    = regexp.MustCompile(`\s(?:([\w.-]+)@)?([\w-]+(?:\.[\w-]+)+|localhost)(?:\s|$)`)
+	passwordRe     = regexp.MustCompile("(?i)\\bpass(?:word)?\\s*(?:is\\s+|[:=]\\s*)[`\"']?([^\\s`\"',)]+)")
+	bareHostPortRe = regexp.MustCompile(`^([\w-]+(?:\.[\w-]+)+|localhost):(\d{1,5})$`)
+)
+
+// ParseEndpoints finds the endpoints in free text: nc/ncat/telnet, ssh and
+// openssl s_client commands, http(s) links to service roots or explicit
+// ports, and a bare "host:port" or "host port" when that is the whole text
+// (as CTFd's connection_info often is).
+func ParseEndpoints(text string) []Endpoint {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+
+	var eps []Endpoint
+	for _, cmd := range extractConnections(text) {
+		if ep, ok := parseCommand(cmd); ok {
+			if ep.Protocol == ProtocolSSH {
+				ep.Password = passwordNear(text, cmd)
+			}
+			eps = append(eps, ep)
+		}
+	}
+	for _, l := range extractLinks(text) {
+		if ep, ok := parseServiceURL(l.URL); ok {
+			eps = append(eps, ep)
+		}
+	}
+
+	if len(eps) == 0 && !strings.ContainsAny(text, "\n\t") {
+		fields := strings.Fields(text)
+		bare := text
+		if len(fields) == 2 {
+			bare = fields[0] + ":" + fields[1]
+		}
+		if m := bareHostPortRe.FindStringSubmatch(bare); m != nil {
+			port, _ := strconv.Atoi(m[2])
+			eps = append(eps, Endpoint{Protocol: ProtocolTCP, Host: m[1], Port: port, Raw: text})
+		}
+	}
+	return MergeEndpoints(eps)
+}
+
+// MergeEndpoints concatenates endpoint lists, dropping later duplicates of
+// the same protocol, host and port.
+func MergeEndpoints(lists ...[]Endpoint) []Endpoint {
+	var out []Endpoint
+	seen := make(map[string]bool)
+	for _, list := range lists {
+		for _, ep := range list {
+			key := string(ep.Protocol) + " " + ep.Address() + " " + ep.URL
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			out = append(out, ep)
+		}
+	}
+	return out
+}
+
+func parseCommand(cmd string) (Endpoint, bool) {
+	if strings.HasPrefix(cmd, "ssh") {
+		args := strings.TrimPrefix(cmd, "ssh") + " "
+		m := sshTargetRe.FindStringSubmatch(sshOptionRe.ReplaceAllString(args, " "))
+		if m == nil {
+			return Endpoint{}, false
+		}
+		ep := Endpoint{Protocol: ProtocolSSH, User: m[1], Host: m[2], Port: 22, Raw: cmd}
+		if p := sshPortRe.FindStringSubmatch(args); p != nil {
+			ep.Port, _ = strconv.Atoi(p[1])
+		}
+		if l := sshLoginRe.FindStringSubmatch(args); l != nil && ep.User == "" {
+			ep.User = l[1]
+		}
+		return ep, validPort(ep.Port)
+	}
+
+	if strings.HasPrefix(cmd, "openssl") {
+		m := sClientRe.FindStringSubmatch(cmd)
+		if m == nil {
+			return Endpoint{}, false
+		}
+		port, _ := strconv.Atoi(m[2])
+		return Endpoint{Protocol: ProtocolTCP, Host: m[1], Port: port, TLS: true, Raw: cmd}, validPort(port)
+	}
+
+	m := ncArgsRe.FindStringSubmatch(cmd)
+	if m == nil {
+		return Endpoint{}, false
+	}
+	port, _ := strconv.Atoi(m[3])
+	ep := Endpoint{Protocol: ProtocolTCP, Host: m[2], Port: port, Raw: cmd}
+	if strings.HasPrefix(cmd, "telnet") {
+		ep.Protocol = ProtocolTelnet
+	} else {
+		ep.TLS = ncSSLRe.MatchString(m[1])
+	}
+	return ep, validPort(port)
+}
+
+// parseServiceURL accepts links that point at a service rather than a page:
+// an explicit port or an empty path. Links to docs, repos and files are not
+// endpoints.
+func parseServiceURL(raw string) (Endpoint, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return Endpoint{}, false
+	}
+	if u.Port() == "" && strings.Trim(u.Path, "/") != "" {
+		return Endpoint{}, false
+	}
+	if isFileLink(raw) {
+		return Endpoint{}, false
+	}
+
+	ep := Endpoint{Protocol: Protocol(u.Scheme), Host: u.Hostname(), URL: raw, Raw: raw}
+	ep.Port = 80
+	if u.Scheme == "https" {
+		ep.Port = 443
+	}
+	if p := u.Port(); p != "" {
+		ep.Port, _ = strconv.Atoi(p)
+	}
+	if u.User != nil {
+		ep.User = u.User.Username()
+		ep.Password, _ = u.User.Password()
+	}
+	return ep, validPort(ep.Port)
+}
+
+// passwordNear looks for "password: x" on the line holding cmd or the next one.
+func passwordNear(text, cmd string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, cmd) {
+			continue
+		}
+		rest := line[strings.Index(line, cmd)+len(cmd):]
+		if i+1 < len(lines) {
+			rest += "\n" + lines[i+1]
+		}
+		if m := passwordRe.FindStringSubmatch(rest); m != nil {
+			return m[1]
+		}
+		return ""
+	}
+	return ""
+}
+
+func validPort(p int) bool {
+	return p > 0 && p < 65536
+}
@@ -0,0 +1,76 @@
+package jeopardy
+
+import (
+	"testing"
+)
+
+func TestParseEndpoints(t *testing.T) {
+	tests := []struct {
+		text string
+		want []Endpoint
+	}{
+		{
+			"Connect with `nc chall.ctf.com 1337`.",
+			[]Endpoint{{Protocol: ProtocolTCP, Host: "chall.ctf.com", Port: 1337}},
+		},
+		{
+			"ncat --ssl secure.ctf.com 443",
+			[]Endpoint{{Protocol: ProtocolTCP, Host: "secure.ctf.com", Port: 443, TLS: true}},
+		},
+		{
+			"ncat -v -ssl secure.ctf.com 8443",
+			[]Endpoint{{Protocol: ProtocolTCP, Host: "secure.ctf.com", Port: 8443, TLS: true}},
+		},
+		{
+			"Run `openssl s_client -quiet -connect tls.ctf.com:31337` to play.",
+			[]Endpoint{{Protocol: ProtocolTCP, Host: "tls.ctf.com", Port: 31337, TLS: true}},
+		},
+		{
+			"ssh ctf@box.example.org -p 2222 (password: hunter2)",
+			[]Endpoint{{Protocol: ProtocolSSH, Host: "box.example.org", Port: 2222, User: "ctf", Password: "hunter2"}},
+		},
+		{
+			"ssh -i key.pem -p 2200 -l guest jail.ctf.io",
+			[]Endpoint{{Protocol: ProtocolSSH, Host: "jail.ctf.io", Port: 2200, User: "guest"}},
+		},
+		{
+			"Web: http://web.ctf.com:8080/ and docs at https://docs.python.org/3/library/",
+			[]Endpoint{{Protocol: ProtocolHTTP, Host: "web.ctf.com", Port: 8080, URL: "http://web.ctf.com:8080/"}},
+		},
+		{"chall.ctf.com:31337", []Endpoint{{Protocol: ProtocolTCP, Host: "chall.ctf.com", Port: 31337}}},
+		{"chall.ctf.com 31337", []Endpoint{{Protocol: ProtocolTCP, Host: "chall.ctf.com", Port: 31337}}},
+		{"nc is a handy tool", nil},
+		{"https://github.com/org/repo", nil},
+	}
+
+	for _, tt := range tests {
+		got := ParseEndpoints(tt.text)
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseEndpoints(%q) = %+v, want %+v", tt.text, got, tt.want)
+			continue
+		}
+		for i := range got {
+			got[i].Raw = ""
+			if got[i] != tt.want[i] {
+				t.Errorf("ParseEndpoints(%q)[%d] = %+v, want %+v", tt.text, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestEndpointCommand(t *testing.T) {
+	tests := []struct {
+		ep   Endpoint
+		want string
+	}{
+		{Endpoint{Protocol: ProtocolTCP, Host: "a.b", Port: 1}, "nc a.b 1"},
+		{Endpoint{Protocol: ProtocolTCP, Host: "a.b", Port: 1, TLS: true}, "ncat --ssl a.b 1"},
+		{Endpoint{Protocol: ProtocolSSH, Host: "a.b", Port: 22, User: "u"}, "ssh u@a.b"},
+		{Endpoint{Protocol: ProtocolSSH, Host: "a.b", Port: 2222}, "ssh -p 2222 a.b"},
+	}
+	for _, tt := range tests {
+		if got := tt.ep.Command(); got != tt.want {
+			t.Errorf("Command() = %q, want %q", got, tt.want)
+		}
+	}
+}
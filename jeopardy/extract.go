@@ -0,0 +1,503 @@
+package jeopardy
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+)
+
+// DefaultMaxExtractSize caps the total decompressed size of one archive
+// when Extractor.MaxSize is zero.
+const DefaultMaxExtractSize = 4 << 30
+
+// defaultMaxExtractFiles caps the number of entries extracted from one archive.
+const defaultMaxExtractFiles = 10000
+
+// ErrUnsupportedArchive is returned for files Extract doesn't know how to unpack.
+var ErrUnsupportedArchive = errors.New("unsupported archive format")
+
+// Extractor unpacks downloaded archives into a directory next to them.
+// Entries are confined to that directory: absolute paths and ".." are
+// refused, links and special files are skipped, and every path component
+// goes through SafeFilename. Archives are extracted to a temporary
+// directory first, so a failed extraction leaves nothing behind.
+type Extractor struct {
+	// MaxSize caps the total decompressed size, in bytes.
+	// Zero means DefaultMaxExtractSize.
+	MaxSize int64
+
+	// Passwords are tried in order on encrypted zip and 7z archives.
+	Passwords []string
+}
+
+// ExtractResult describes a completed extraction.
+type ExtractResult struct {
+	Dir      string   // directory the archive was extracted to
+	Files    []string // extracted files, relative to Dir, slash-separated
+	Skipped  []string // entries refused as unsafe or unsupported
+	Password string   // password that unlocked the archive, if any
+}
+
+type archiveKind int
+
+const (
+	archiveNone archiveKind = iota
+	archiveZip
+	archiveTar
+	archiveTarGz
+	archiveTarBz2
+	archiveTarXz
+	archive7z
+)
+
+// archiveSuffixes maps file name suffixes to archive kinds. Longer suffixes
+// come first so ".tar.gz" wins over ".gz"-style matches.
+var archiveSuffixes = []struct {
+	suffix string
+	kind   archiveKind
+}{
+	{".tar.gz", archiveTarGz},
+	{".tar.bz2", archiveTarBz2},
+	{".tar.xz", archiveTarXz},
+	{".tgz", archiveTarGz},
+	{".tbz2", archiveTarBz2},
+	{".txz", archiveTarXz},
+	{".tar", archiveTar},
+	{".zip", archiveZip},
+	{".7z", archive7z},
+}
+
+func detectArchive(name string) (archiveKind, string) {
+	lower := strings.ToLower(name)
+	for _, s := range archiveSuffixes {
+		if strings.HasSuffix(lower, s.suffix) {
+			return s.kind, name[:len(name)-len(s.suffix)]
+		}
+	}
+	return archiveNone, ""
+}
+
+// IsArchive reports whether name looks like an archive Extract can unpack.
+func IsArchive(name string) bool {
+	kind, _ := detectArchive(name)
+	return kind != archiveNone
+}
+
+// Extract unpacks the archive at path into a sibling directory named after
+// it without its extension ("handout.tar.gz" goes to "handout"). It refuses
+// to overwrite an existing directory.
+func (e *Extractor) Extract(ctx context.Context, path string) (*ExtractResult, error) {
+	kind, stem := detectArchive(filepath.Base(path))
+	if kind == archiveNone {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), ErrUnsupportedArchive)
+	}
+
+	parent := filepath.Dir(path)
+	dest := filepath.Join(parent, SafeFilename(stem))
+	if SafeFilename(stem) == "" || dest == path {
+		dest = path + "_extracted"
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		return nil, fmt.Errorf("%s already exists", dest)
+	}
+
+	tmp, err := os.MkdirTemp(parent, ".extract-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	x := &extraction{ctx: ctx, root: tmp, maxSize: e.MaxSize, maxFiles: defaultMaxExtractFiles}
+	if x.maxSize <= 0 {
+		x.maxSize = DefaultMaxExtractSize
+	}
+
+	switch kind {
+	case archiveZip:
+		err = x.zip(path, e.Passwords)
+	case archive7z:
+		err = x.sevenZip(path, e.Passwords)
+	default:
+		err = x.tar(path, kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("extract %s: %w", filepath.Base(path), err)
+	}
+
+	if err := os.Rename(tmp, dest); err != nil {
+		return nil, err
+	}
+	return &ExtractResult{Dir: dest, Files: x.files, Skipped: x.skipped, Password: x.password}, nil
+}
+
+// extraction tracks the state of a single archive being unpacked.
+type extraction struct {
+	ctx      context.Context
+	root     string
+	maxSize  int64
+	maxFiles int
+
+	written  int64
+	files    []string
+	skipped  []string
+	password string
+}
+
+// target maps an archive entry name to a path under the extraction root,
+// or returns ok=false if the entry would escape it.
+func (x *extraction) target(name string) (rel string, ok bool) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(name, "/") || filepath.VolumeName(name) != "" {
+		return "", false
+	}
+	var parts []string
+	for _, part := range strings.Split(name, "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			return "", false
+		}
+		safe := SafeFilename(part)
+		if safe == "" {
+			return "", false
+		}
+		parts = append(parts, safe)
+	}
+	if len(parts) == 0 {
+		return "", false
+	}
+	return strings.Join(parts, "/"), true
+}
+
+func (x *extraction) skip(name, reason string) {
+	x.skipped = append(x.skipped, name+" ("+reason+")")
+}
+
+func (x *extraction) mkdir(name string) error {
+	rel, ok := x.target(name)
+	if !ok {
+		x.skip(name, "unsafe path")
+		return nil
+	}
+	return os.MkdirAll(filepath.Join(x.root, filepath.FromSlash(rel)), 0755)
+}
+
+// create writes one regular file, enforcing the size and count caps.
+// On failure the partial file is removed and not counted.
+func (x *extraction) create(name string, executable bool, r io.Reader) error {
+	if err := x.ctx.Err(); err != nil {
+		return err
+	}
+	rel, ok := x.target(name)
+	if !ok {
+		x.skip(name, "unsafe path")
+		return nil
+	}
+	if len(x.files) >= x.maxFiles {
+		return fmt.Errorf("more than %d entries", x.maxFiles)
+	}
+
+	outPath := filepath.Join(x.root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if executable {
+		mode = 0755
+	}
+	out, err := os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	// Read one byte past the remaining budget to detect overflow.
+	n, err := io.Copy(out, io.LimitReader(r, x.maxSize-x.written+1))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && x.written+n > x.maxSize {
+		err = fmt.Errorf("decompressed size exceeds %d bytes: %w", x.maxSize, ErrTooLarge)
+	}
+	if err != nil {
+		os.Remove(outPath)
+		return err
+	}
+	x.written += n
+	x.files = append(x.files, rel)
+	return nil
+}
+
+func (x *extraction) tar(path string, kind archiveKind) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = bufio.NewReader(f)
+	switch kind {
+	case archiveTarGz:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case archiveTarBz2:
+		r = bzip2.NewReader(r)
+	case archiveTarXz:
+		if r, err = xz.NewReader(r); err != nil {
+			return err
+		}
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = x.mkdir(hdr.Name)
+		case tar.TypeReg:
+			err = x.create(hdr.Name, hdr.Mode&0111 != 0, tr)
+		default:
+			x.skip(hdr.Name, "not a regular file")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (x *extraction) zip(path string, passwords []string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			err = x.mkdir(f.Name)
+		case mode.IsRegular():
+			err = x.zipEntry(f, passwords)
+		default:
+			x.skip(f.Name, "not a regular file")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zipEntry extracts one zip entry. For encrypted entries, the password that
+// worked last is tried first, then every configured one.
+func (x *extraction) zipEntry(f *zip.File, passwords []string) error {
+	if f.Flags&0x1 == 0 {
+		return x.zipEntryWith(f, "")
+	}
+
+	candidates := passwords
+	if x.password != "" {
+		candidates = append([]string{x.password}, passwords...)
+	}
+	for _, pw := range candidates {
+		err := x.zipEntryWith(f, pw)
+		if errors.Is(err, errZipPassword) || errors.Is(err, errZipChecksum) {
+			continue
+		}
+		if err == nil {
+			x.password = pw
+		}
+		return err
+	}
+	return fmt.Errorf("%s: none of the %d configured passwords worked", f.Name, len(passwords))
+}
+
+func (x *extraction) zipEntryWith(f *zip.File, password string) error {
+	rc, err := openZipEntry(f, password)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return x.create(f.Name, f.Mode()&0111 != 0, rc)
+}
+
+// sevenZip shells out to a 7-Zip binary, since there's no 7z support in the
+// standard library. The listing is validated before anything is written.
+func (x *extraction) sevenZip(path string, passwords []string) error {
+	bin := find7z()
+	if bin == "" {
+		return fmt.Errorf("7z archives need 7zz, 7z or 7za on PATH: %w", ErrUnsupportedArchive)
+	}
+
+	// -p is always passed so 7-Zip never stops to prompt for a password.
+	password, ok := "", false
+	for _, pw := range append([]string{""}, passwords...) {
+		if exec.CommandContext(x.ctx, bin, "t", "-p"+pw, "-bd", path).Run() == nil {
+			password, ok = pw, true
+			break
+		}
+	}
+	if !ok {
+		return fmt.Errorf("archive is corrupt or none of the %d configured passwords worked", len(passwords))
+	}
+
+	listing, err := exec.CommandContext(x.ctx, bin, "l", "-slt", "-p"+password, "-bd", path).Output()
+	if err != nil {
+		return fmt.Errorf("list archive: %w", err)
+	}
+	var total int64
+	entries := parse7zListing(string(listing))
+	for _, entry := range entries {
+		if _, ok := x.target(entry.path); !ok {
+			return fmt.Errorf("unsafe entry %q", entry.path)
+		}
+		// Depending on the version, 7-Zip may follow a link it just
+		// extracted when writing a later entry of the same name, so links
+		// are refused before anything is written.
+		if entry.link {
+			return fmt.Errorf("link entry %q", entry.path)
+		}
+		total += entry.size
+	}
+	if total > x.maxSize {
+		return fmt.Errorf("decompressed size exceeds %d bytes: %w", x.maxSize, ErrTooLarge)
+	}
+	if len(entries) > x.maxFiles {
+		return fmt.Errorf("more than %d entries", x.maxFiles)
+	}
+
+	// Extract into a scratch directory, then move regular files over
+	// through create so they get the same checks as every other format.
+	scratch, err := os.MkdirTemp(x.root, ".7z-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(scratch)
+	cmd := exec.CommandContext(x.ctx, bin, "x", "-y", "-p"+password, "-bd", "-o"+scratch, path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("7z: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	if password != "" {
+		x.password = password
+	}
+
+	return filepath.WalkDir(scratch, func(p string, d os.DirEntry, err error) error {
+		if err != nil || p == scratch {
+			return err
+		}
+		rel, err := filepath.Rel(scratch, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		switch {
+		case d.IsDir():
+			return x.mkdir(rel)
+		case d.Type().IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return x.create(rel, info.Mode()&0111 != 0, f)
+		default:
+			x.skip(rel, "not a regular file")
+			return nil
+		}
+	})
+}
+
+func find7z() string {
+	for _, name := range []string{"7zz", "7z", "7za"} {
+		if p, err := exec.LookPath(name); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+type sevenZipEntry struct {
+	path string
+	size int64
+	link bool
+}
+
+// parse7zListing parses the entries of "7z l -slt" output, skipping the
+// archive's own header block.
+func parse7zListing(out string) []sevenZipEntry {
+	out = strings.ReplaceAll(out, "\r\n", "\n")
+	_, body, found := strings.Cut(out, "----------")
+	if !found {
+		return nil
+	}
+	var entries []sevenZipEntry
+	for _, block := range strings.Split(body, "\n\n") {
+		var entry sevenZipEntry
+		for _, line := range strings.Split(block, "\n") {
+			key, value, ok := strings.Cut(strings.TrimSpace(line), " = ")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "Path":
+				entry.path = value
+			case "Size":
+				entry.size, _ = strconv.ParseInt(value, 10, 64)
+			case "Attributes", "Mode":
+				entry.link = entry.link || isLinkMode(value)
+			case "Symbolic Link", "Hard Link", "Link":
+				entry.link = entry.link || value != ""
+			}
+		}
+		if entry.path != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// isLinkMode reports whether a 7-Zip Attributes or Mode value marks a
+// symlink, either as an ls-style mode ("A_ lrwxrwxrwx") or as a number
+// with the unix mode in its high 16 bits ("0xA1FF8020").
+func isLinkMode(value string) bool {
+	for _, field := range strings.Fields(value) {
+		field = strings.TrimPrefix(field, "-")
+		if len(field) == 10 && field[0] == 'l' {
+			return true
+		}
+		if hex, ok := strings.CutPrefix(field, "0x"); ok {
+			if n, err := strconv.ParseUint(hex, 16, 32); err == nil && n&0x8000 != 0 && (n>>16)&0xF000 == 0xA000 {
+				return true
+			}
+		}
+	}
+	return false
+}
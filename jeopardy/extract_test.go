@@ -0,0 +1,195 @@
+package jeopardy
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeZip(t *testing.T, path string, entries map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, content := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractZipSlip(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "handout.zip")
+	writeZip(t, archive, map[string]string{
+		"chall/main.c":     "int main() {}",
+		"../../evil.txt":   "pwned",
+		"/etc/evil.txt":    "pwned",
+		`..\win-evil.txt`:  "pwned",
+		"./chall/flag.txt": "FLAG{fake}",
+	})
+
+	e := &Extractor{}
+	res, err := e.Extract(context.Background(), archive)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if res.Dir != filepath.Join(dir, "handout") {
+		t.Errorf("Dir = %q", res.Dir)
+	}
+	slices.Sort(res.Files)
+	if want := []string{"chall/flag.txt", "chall/main.c"}; !slices.Equal(res.Files, want) {
+		t.Errorf("Files = %v, want %v", res.Files, want)
+	}
+	if len(res.Skipped) != 3 {
+		t.Errorf("Skipped = %v, want 3 entries", res.Skipped)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "..", "evil.txt")); err == nil {
+		t.Error("entry escaped the extraction directory")
+	}
+}
+
+func TestExtractTarGzSizeCap(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "bomb.tar.gz")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	body := strings.Repeat("A", 4096)
+	for _, name := range []string{"a", "b", "c"} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(body))
+	}
+	tw.WriteHeader(&tar.Header{Name: "link", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink})
+	tw.Close()
+	gz.Close()
+	f.Close()
+
+	e := &Extractor{MaxSize: 10000}
+	if _, err := e.Extract(context.Background(), archive); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("err = %v, want ErrTooLarge", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bomb")); !os.IsNotExist(err) {
+		t.Error("failed extraction left a directory behind")
+	}
+
+	e.MaxSize = 0
+	res, err := e.Extract(context.Background(), archive)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if len(res.Files) != 3 || len(res.Skipped) != 1 {
+		t.Errorf("Files = %v, Skipped = %v", res.Files, res.Skipped)
+	}
+}
+
+func TestExtractEncryptedZip(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "infected.zip")
+	data, err := os.ReadFile("testdata/infected.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(archive, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &Extractor{Passwords: []string{"wrong"}}
+	if _, err := e.Extract(context.Background(), archive); err == nil {
+		t.Fatal("expected error with wrong password")
+	}
+
+	e.Passwords = []string{"wrong", "infected"}
+	res, err := e.Extract(context.Background(), archive)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if res.Password != "infected" {
+		t.Errorf("Password = %q", res.Password)
+	}
+	flag, err := os.ReadFile(filepath.Join(res.Dir, "sample", "flag.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(flag) != "FLAG{zipcrypto}\n" {
+		t.Errorf("flag = %q", flag)
+	}
+	numbers, err := os.ReadFile(filepath.Join(res.Dir, "sample", "numbers.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(numbers), "1999\n2000\n") {
+		t.Error("deflated entry decrypted incorrectly")
+	}
+}
+
+func TestIsArchive(t *testing.T) {
+	for name, want := range map[string]bool{
+		"a.zip": true, "A.TAR.GZ": true, "b.tgz": true, "c.tar.xz": true,
+		"d.7z": true, "e.tar": true, "f.gz": false, "g.txt": false,
+	} {
+		if got := IsArchive(name); got != want {
+			t.Errorf("IsArchive(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestParse7zListingLinks(t *testing.T) {
+	listing := `7-Zip 23.01 (x64)
+
+Listing archive: a.7z
+
+--
+Path = a.7z
+Type = 7z
+
+----------
+Path = notes.txt
+Size = 12
+Attributes = A_ -rw-r--r--
+
+Path = evil
+Size = 11
+Attributes = A_ lrwxrwxrwx
+
+Path = packed
+Size = 11
+Attributes = 0xA1FF8020
+
+Path = dir/link
+Size = 0
+Mode = lrwxrwxrwx
+Symbolic Link = /etc
+`
+	entries := parse7zListing(listing)
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries, want 4", len(entries))
+	}
+	for i, want := range []bool{false, true, true, true} {
+		if entries[i].link != want {
+			t.Errorf("%s: link = %v, want %v", entries[i].path, entries[i].link, want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package jeopardy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Opener is implemented by files that can stream their own contents,
+// for example to refresh expiring download tokens.
+type Opener interface {
+	Open(ctx context.Context) (io.ReadCloser, error)
+}
+
+// rangeOpener is implemented by built-in files that handle their own
+// requests but can still resume at an offset. ifRange is the validator
+// the offset's data was downloaded under, sent as If-Range.
+type rangeOpener interface {
+	openRange(ctx context.Context, offset int64, ifRange string) (*http.Response, error)
+}
+
+// OpenFile opens a challenge attachment for reading. Files implementing
+// Opener are opened directly; others are fetched from their DownloadURL.
+// The caller must close the returned reader.
+func OpenFile(ctx context.Context, f File) (io.ReadCloser, error) {
+	if o, ok := f.(Opener); ok {
+		return o.Open(ctx)
+	}
+	body, _, _, _, err := openAt(ctx, f, 0, "")
+	return body, err
+}
+
+// openAt opens f starting at offset when the source supports ranges, and
+// the data there still matches ifRange. start is the offset the returned
+// data actually begins at, which is 0 when the server ignored the range or
+// the file changed; total is the full size or -1. validator identifies
+// this version of the file for a later resume, or is "" if the server
+// gave none.
+func openAt(ctx context.Context, f File, offset int64, ifRange string) (body io.ReadCloser, start, total int64, validator string, err error) {
+	var resp *http.Response
+	switch o := f.(type) {
+	case rangeOpener:
+		resp, err = o.openRange(ctx, offset, ifRange)
+	case Opener:
+		body, err = o.Open(ctx)
+		return body, 0, -1, "", err
+	default:
+		var info *DownloadInfo
+		if info, err = f.DownloadURL(ctx); err != nil {
+			return nil, 0, 0, "", fmt.Errorf("get download url: %w", err)
+		}
+		if resp, err = startDownload(ctx, newDownloadClient(nil), info, offset, ifRange); err == nil {
+			err = checkDownloadStatus(resp, offset)
+		}
+	}
+	if err != nil {
+		return nil, 0, 0, "", err
+	}
+
+	start, total = 0, resp.ContentLength
+	if resp.StatusCode == http.StatusPartialContent {
+		if start, total, err = parseContentRange(resp.Header.Get("Content-Range")); err != nil {
+			resp.Body.Close()
+			return nil, 0, 0, "", err
+		}
+	}
+	return resp.Body, start, total, rangeValidator(resp.Header), nil
+}
+
+// rangeValidator picks what If-Range can name this response by: a strong
+// ETag, or else Last-Modified.
+func rangeValidator(h http.Header) string {
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return h.Get("Last-Modified")
+}
+
+// startDownload issues a GET for info, asking for the bytes from offset on
+// when offset is positive, as long as the file still matches ifRange, and
+// returns the response whatever its status.
+func startDownload(ctx context.Context, client *http.Client, info *DownloadInfo, offset int64, ifRange string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", info.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range info.Headers {
+		req.Header.Set(k, v)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", ifRange)
+	}
+	return client.Do(req)
+}
+
+// checkDownloadStatus closes resp and returns an error unless it carries
+// file data: a 200, or a 206 for a ranged request.
+func checkDownloadStatus(resp *http.Response, offset int64) error {
+	if resp.StatusCode == http.StatusOK || (offset > 0 && resp.StatusCode == http.StatusPartialContent) {
+		return nil
+	}
+	resp.Body.Close()
+	return fmt.Errorf("download failed: %s", resp.Status)
+}
+
+// parseContentRange parses a "bytes start-end/total" header.
+func parseContentRange(value string) (start, total int64, err error) {
+	spec, ok := strings.CutPrefix(value, "bytes ")
+	rng, size, ok2 := strings.Cut(spec, "/")
+	first, _, ok3 := strings.Cut(rng, "-")
+	if !ok || !ok2 || !ok3 {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", value)
+	}
+	if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", value)
+	}
+	total = -1
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid Content-Range %q", value)
+		}
+	}
+	return start, total, nil
+}
+
+// newDownloadClient returns a client for file downloads. It has no overall
+// timeout, since large attachments can take a while, and relies on the
+// request context instead. A nil transport means defaultTransport.
+func newDownloadClient(transport http.RoundTripper) *http.Client {
+	if transport == nil {
+		transport = defaultTransport
+	}
+	return &http.Client{
+		Transport:     transport,
+		CheckRedirect: stripCrossOriginHeaders,
+	}
+}
+
+// redirectSafeHeaders are kept when a download is redirected to another origin.
+var redirectSafeHeaders = []string{"Accept", "Accept-Encoding", "User-Agent", "Range", "If-Range"}
+
+// stripCrossOriginHeaders drops every credential-bearing header when a redirect
+// leaves the original origin. Presigned storage URLs (S3 and friends) reject
+// requests that carry a second set of credentials, and they must never see ours.
+func stripCrossOriginHeaders(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return fmt.Errorf("stopped after 10 redirects")
+	}
+	if sameOrigin(req.URL, via[0].URL) {
+		return nil
+	}
+	kept := make(http.Header)
+	for _, k := range redirectSafeHeaders {
+		if v := req.Header.Values(k); len(v) > 0 {
+			kept[k] = v
+		}
+	}
+	req.Header = kept
+	return nil
+}
+
+func sameOrigin(a, b *url.URL) bool {
+	return a.Scheme == b.Scheme && a.Host == b.Host
+}
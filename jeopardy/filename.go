@@ -0,0 +1,110 @@
+package jeopardy
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxFilenameBytes leaves headroom below the usual 255-byte limit for
+// collision suffixes and the downloader's ".part" extension.
+const maxFilenameBytes = 200
+
+// windowsReserved are device names Windows refuses as file names,
+// with or without an extension.
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SafeFilename turns a server-supplied name into a single path component
+// that can be created on Linux, macOS and Windows without escaping its
+// directory. It returns "" when nothing usable is left (e.g. "..").
+func SafeFilename(name string) string {
+	name = norm.NFC.String(strings.ToValidUTF8(name, "_"))
+
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20 || r == 0x7f || unicode.Is(unicode.Cc, r):
+			return '_'
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, name)
+
+	// Windows silently drops trailing dots and spaces.
+	name = strings.TrimRight(strings.TrimSpace(name), ". ")
+	if strings.Trim(name, "._ ") == "" {
+		return ""
+	}
+
+	base, _, _ := strings.Cut(name, ".")
+	if windowsReserved[strings.ToUpper(strings.TrimSpace(base))] {
+		name = "_" + name
+	}
+
+	return truncateFilename(name, maxFilenameBytes)
+}
+
+// truncateFilename shortens name to at most limit bytes, keeping the
+// extension and never splitting a UTF-8 sequence.
+func truncateFilename(name string, limit int) string {
+	if len(name) <= limit {
+		return name
+	}
+	ext := filepath.Ext(name)
+	if len(ext) > limit/4 {
+		ext = ""
+	}
+	stem := name[:len(name)-len(ext)]
+	cut := limit - len(ext)
+	for cut > 0 && !utf8.RuneStart(stem[cut]) {
+		cut--
+	}
+	return stem[:cut] + ext
+}
+
+// FileNamer assigns safe, unique names within a single directory.
+// Names are compared case-insensitively, since that's how macOS and
+// Windows file systems behave. The zero value is ready to use.
+type FileNamer struct {
+	used map[string]bool
+}
+
+// Reserve marks name as taken, e.g. for metadata files written alongside
+// attachments.
+func (n *FileNamer) Reserve(name string) {
+	if n.used == nil {
+		n.used = make(map[string]bool)
+	}
+	n.used[strings.ToLower(name)] = true
+}
+
+// Name returns a safe version of name that hasn't been handed out yet,
+// appending "-2", "-3", ... before the extension on collisions. Unusable
+// names fall back to "file".
+func (n *FileNamer) Name(name string) string {
+	safe := SafeFilename(name)
+	if safe == "" {
+		safe = "file"
+	}
+	ext := filepath.Ext(safe)
+	stem := strings.TrimSuffix(safe, ext)
+	if stem == "" {
+		stem, ext = safe, ""
+	}
+	candidate := safe
+	for i := 2; n.used[strings.ToLower(candidate)]; i++ {
+		candidate = fmt.Sprintf("%s-%d%s", stem, i, ext)
+	}
+	n.Reserve(candidate)
+	return candidate
+}
@@ -0,0 +1,60 @@
+package jeopardy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSafeFilename(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"handout.zip", "handout.zip"},
+		{"../../.bashrc", ".._.._.bashrc"},
+		{"..", ""},
+		{".", ""},
+		{"", ""},
+		{"   ", ""},
+		{"a\x00b", "a_b"},
+		{`dir\file`, "dir_file"},
+		{"what?.txt", "what_.txt"},
+		{"trailing. . ", "trailing"},
+		{"CON", "_CON"},
+		{"nul.txt", "_nul.txt"},
+		{"com1.tar.gz", "_com1.tar.gz"},
+		{"console.log", "console.log"},
+		{"café", "café"},
+		{"bad\xffutf8", "bad_utf8"},
+	}
+	for _, tt := range tests {
+		if got := SafeFilename(tt.in); got != tt.want {
+			t.Errorf("SafeFilename(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSafeFilenameLength(t *testing.T) {
+	got := SafeFilename(strings.Repeat("é", 300) + ".tar.gz")
+	if len(got) > maxFilenameBytes {
+		t.Errorf("len = %d, want <= %d", len(got), maxFilenameBytes)
+	}
+	if !strings.HasSuffix(got, ".gz") {
+		t.Errorf("extension lost: %q", got)
+	}
+	if !strings.HasPrefix(got, "é") || strings.ContainsRune(got, '�') {
+		t.Errorf("truncation split a rune: %q", got)
+	}
+}
+
+func TestFileNamer(t *testing.T) {
+	var n FileNamer
+	n.Reserve("challenge.json")
+
+	inputs := []string{"chall.zip", "Chall.zip", "challenge.json", "..", "", "chall.zip", ".env", ".env"}
+	want := []string{"chall.zip", "Chall-2.zip", "challenge-2.json", "file", "file-2", "chall-3.zip", ".env", ".env-2"}
+	for i, in := range inputs {
+		if got := n.Name(in); got != want[i] {
+			t.Errorf("Name(%q) = %q, want %q", in, got, want[i])
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package jeopardy
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -52,6 +56,79 @@ func TestBuildCTFdCookie(t *testing.T) {
 	}
 }
 
+func TestBuildCTFdInvalidMode(t *testing.T) {
+	_, err := Build("ctfd_token", map[string]string{
+		"base_url": "https://ctf.example.com",
+		"token":    "test-token",
+		"mode":     "clans",
+	})
+	if err == nil {
+		t.Fatal("expected error for invalid mode")
+	}
+}
+
+func TestCTFdSolvesTeamMode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/teams/me":
+			fmt.Fprint(w, `{"success": true, "data": {"id": 1, "name": "team"}}`)
+		case "/api/v1/teams/me/solves":
+			fmt.Fprint(w, `{"success": true, "data": [
+				{"challenge_id": 3, "date": "2025-01-01T12:00:00Z", "challenge": {"value": 100}, "user": {"id": 7, "name": "alice"}},
+				{"challenge_id": 4, "date": "2025-01-01T13:00:00Z", "challenge": {"value": 200}, "user": {"id": 8, "name": "bob"}}
+			]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := newCTFd(srv.URL, tokenAuth("test-token"), "")
+	if err != nil {
+		t.Fatalf("newCTFd failed: %v", err)
+	}
+	solves, err := c.Solves(context.Background())
+	if err != nil {
+		t.Fatalf("Solves failed: %v", err)
+	}
+	if len(solves) != 2 {
+		t.Fatalf("got %d solves, want 2", len(solves))
+	}
+	if solves[1].UserName != "bob" || solves[1].UserID != "8" || solves[1].Points != 200 {
+		t.Errorf("unexpected solve: %+v", solves[1])
+	}
+	if c.userMode != ctfdTeamsMode {
+		t.Errorf("mode = %q, want %q", c.userMode, ctfdTeamsMode)
+	}
+}
+
+func TestCTFdSolvesUserMode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/users/me/solves":
+			fmt.Fprint(w, `{"success": true, "data": [{"challenge_id": 3, "date": "2025-01-01T12:00:00Z"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := newCTFd(srv.URL, tokenAuth("test-token"), "")
+	if err != nil {
+		t.Fatalf("newCTFd failed: %v", err)
+	}
+	solves, err := c.Solves(context.Background())
+	if err != nil {
+		t.Fatalf("Solves failed: %v", err)
+	}
+	if len(solves) != 1 || solves[0].ChallengeID != "3" {
+		t.Errorf("unexpected solves: %+v", solves)
+	}
+	if c.userMode != ctfdUsersMode {
+		t.Errorf("mode = %q, want %q", c.userMode, ctfdUsersMode)
+	}
+}
+
 func TestBuildRCTF(t *testing.T) {
 	backend, err := Build("rctf", map[string]string{
 		"base_url":   "https://rctf.example.com",
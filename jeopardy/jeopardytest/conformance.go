@@ -0,0 +1,288 @@
+// Package jeopardytest checks that a jeopardy.Backend behaves the way the
+// rest of ctf-sync expects.
+//
+// Run drives a backend against a scripted Platform. The built-in backends
+// talk to the mock servers in this package; a custom backend needs a mock
+// of its own platform that serves the Platform it is given:
+//
+//	func TestConformance(t *testing.T) {
+//		jeopardytest.Run(t, jeopardytest.Harness{
+//			New: func(t *testing.T, p *jeopardytest.Platform) jeopardy.Backend {
+//				srv := httptest.NewServer(myPlatformMock(p))
+//				t.Cleanup(srv.Close)
+//				b, err := jeopardy.Build("mine", map[string]string{"url": srv.URL})
+//				if err != nil {
+//					t.Fatal(err)
+//				}
+//				return b
+//			},
+//			Statuses: []jeopardy.SubmitStatus{jeopardy.Duplicate, jeopardy.RateLimited},
+//		})
+//	}
+//
+// The mock should call Platform.Wait before answering each request, check
+// flags with Platform.Submit and list solves from Platform.Solves.
+package jeopardytest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy"
+)
+
+// Harness connects the conformance suite to the backend under test.
+type Harness struct {
+	// New returns a backend talking to a mock platform serving p. It is
+	// called once per check, each time with a fresh platform.
+	New func(t *testing.T, p *Platform) jeopardy.Backend
+	// Statuses lists the submit results the platform can report besides
+	// Accepted and Rejected, which every backend must map.
+	Statuses []jeopardy.SubmitStatus
+}
+
+// cancelTimeout bounds how long a backend may keep going once its context
+// is done.
+const cancelTimeout = 5 * time.Second
+
+// Run checks that:
+//   - Fetch returns every challenge with a stable, unique ID;
+//   - Submit maps each platform result to its SubmitStatus;
+//   - Solves reports challenges by the IDs Fetch returned;
+//   - DownloadURL gives a URL that serves the attachment, as does OpenFile;
+//   - every call gives up with the context's error once it is done.
+func Run(t *testing.T, h Harness) {
+	t.Run("StableIDs", func(t *testing.T) { testStableIDs(t, h) })
+	t.Run("Submit", func(t *testing.T) { testSubmit(t, h) })
+	t.Run("Solves", func(t *testing.T) { testSolves(t, h) })
+	t.Run("Files", func(t *testing.T) { testFiles(t, h) })
+	t.Run("Cancel", func(t *testing.T) { testCancel(t, h) })
+}
+
+// fetch builds a backend against a fresh platform and matches the fetched
+// challenges to the platform's by name.
+func fetch(t *testing.T, h Harness) (*Platform, jeopardy.Backend, map[string]jeopardy.Challenge) {
+	t.Helper()
+	p := NewPlatform()
+	b := h.New(t, p)
+	challenges, err := b.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	byName := make(map[string]jeopardy.Challenge)
+	for _, c := range challenges {
+		byName[c.Name] = c
+	}
+	for _, want := range p.Challenges {
+		if _, ok := byName[want.Name]; !ok {
+			t.Fatalf("Fetch did not return challenge %q", want.Name)
+		}
+	}
+	return p, b, byName
+}
+
+func testStableIDs(t *testing.T, h Harness) {
+	p, b, first := fetch(t, h)
+	again, err := b.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("second Fetch failed: %v", err)
+	}
+	if len(again) != len(p.Challenges) {
+		t.Errorf("second Fetch returned %d challenges, want %d", len(again), len(p.Challenges))
+	}
+
+	seen := make(map[string]bool)
+	for _, c := range again {
+		if c.ID == "" {
+			t.Errorf("challenge %q has an empty ID", c.Name)
+		}
+		if seen[c.ID] {
+			t.Errorf("ID %q is used by more than one challenge", c.ID)
+		}
+		seen[c.ID] = true
+		if prev, ok := first[c.Name]; ok && prev.ID != c.ID {
+			t.Errorf("challenge %q changed ID from %q to %q", c.Name, prev.ID, c.ID)
+		}
+	}
+	for _, want := range p.Challenges {
+		got := first[want.Name]
+		if got.Category != want.Category || got.Points != want.Points {
+			t.Errorf("challenge %q = %s/%d points, want %s/%d", want.Name, got.Category, got.Points, want.Category, want.Points)
+		}
+	}
+}
+
+func testSubmit(t *testing.T, h Harness) {
+	p, b, challenges := fetch(t, h)
+	ctx := context.Background()
+	solved, unsolved := p.Challenges[0], p.Challenges[1]
+
+	submit := func(c Challenge, flag string, want jeopardy.SubmitStatus) {
+		t.Helper()
+		res, err := b.Submit(ctx, challenges[c.Name].ID, flag)
+		if err != nil {
+			t.Errorf("Submit failed: %v, want %s", err, want)
+			return
+		}
+		if res.Status != want {
+			t.Errorf("Submit = %s (%q), want %s", res.Status, res.Message, want)
+		}
+	}
+
+	submit(unsolved, "flag{wrong}", jeopardy.Rejected)
+	for _, status := range h.Statuses {
+		switch status {
+		case jeopardy.Accepted, jeopardy.Rejected:
+		case jeopardy.Duplicate:
+			submit(solved, solved.Flag, jeopardy.Duplicate)
+		default:
+			p.ScriptSubmit(status)
+			submit(unsolved, "flag{wrong}", status)
+		}
+	}
+	submit(unsolved, unsolved.Flag, jeopardy.Accepted)
+	if !p.Solved(unsolved.ID) {
+		t.Errorf("the platform did not record the accepted flag")
+	}
+}
+
+func testSolves(t *testing.T, h Harness) {
+	p, b, challenges := fetch(t, h)
+	ctx := context.Background()
+	ids := make(map[string]string) // backend ID -> name
+	for name, c := range challenges {
+		ids[c.ID] = name
+	}
+
+	check := func(want ...string) {
+		t.Helper()
+		solves, err := b.Solves(ctx)
+		if err != nil {
+			t.Fatalf("Solves failed: %v", err)
+		}
+		var got []string
+		for _, s := range solves {
+			name, ok := ids[s.ChallengeID]
+			if !ok {
+				t.Errorf("solve of unknown challenge ID %q", s.ChallengeID)
+				continue
+			}
+			got = append(got, name)
+		}
+		slices.Sort(got)
+		slices.Sort(want)
+		if !slices.Equal(got, want) {
+			t.Errorf("solved %q, want %q", got, want)
+		}
+	}
+
+	check(p.Challenges[0].Name)
+	c := p.Challenges[2]
+	if _, err := b.Submit(ctx, challenges[c.Name].ID, c.Flag); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	check(p.Challenges[0].Name, c.Name)
+}
+
+func testFiles(t *testing.T, h Harness) {
+	p, _, challenges := fetch(t, h)
+	ctx := context.Background()
+	for _, want := range p.Challenges {
+		got := challenges[want.Name]
+		if len(got.Files) != len(want.Files) {
+			t.Errorf("challenge %q has %d files, want %d", want.Name, len(got.Files), len(want.Files))
+			continue
+		}
+		for _, f := range got.Files {
+			content, ok := p.File(want.ID, f.Name())
+			if !ok {
+				t.Errorf("challenge %q has unexpected file %q", want.Name, f.Name())
+				continue
+			}
+
+			info, err := f.DownloadURL(ctx)
+			if err != nil || info == nil || info.URL == "" {
+				t.Errorf("DownloadURL(%s) = %v, %v", f.Name(), info, err)
+				continue
+			}
+			if data, err := download(ctx, info); err != nil || string(data) != string(content) {
+				t.Errorf("GET %s = %q (%v), want %q", info.URL, data, err, content)
+			}
+
+			r, err := jeopardy.OpenFile(ctx, f)
+			if err != nil {
+				t.Errorf("OpenFile(%s) failed: %v", f.Name(), err)
+				continue
+			}
+			data, err := io.ReadAll(r)
+			r.Close()
+			if err != nil || string(data) != string(content) {
+				t.Errorf("OpenFile(%s) read %q (%v), want %q", f.Name(), data, err, content)
+			}
+		}
+	}
+}
+
+func download(ctx context.Context, info *jeopardy.DownloadInfo) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", info.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range info.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func testCancel(t *testing.T, h Harness) {
+	p, b, challenges := fetch(t, h)
+	id := challenges[p.Challenges[1].Name].ID
+	calls := []struct {
+		name string
+		call func(context.Context) error
+	}{
+		{"Fetch", func(ctx context.Context) error { _, err := b.Fetch(ctx); return err }},
+		{"Submit", func(ctx context.Context) error { _, err := b.Submit(ctx, id, "flag{wrong}"); return err }},
+		{"Solves", func(ctx context.Context) error { _, err := b.Solves(ctx); return err }},
+	}
+
+	for _, c := range calls {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := c.call(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s with a canceled context = %v, want context.Canceled", c.name, err)
+		}
+	}
+
+	// A platform that stops answering must not hold the call past its
+	// deadline.
+	release := p.Stall()
+	defer release()
+	for _, c := range calls {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		done := make(chan error, 1)
+		go func() { done <- c.call(ctx) }()
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("%s on a stalled platform = %v, want context.DeadlineExceeded", c.name, err)
+			}
+		case <-time.After(cancelTimeout):
+			t.Errorf("%s ignored its deadline on a stalled platform", c.name)
+		}
+		cancel()
+	}
+}
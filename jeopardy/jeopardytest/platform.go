@@ -0,0 +1,60 @@
+package jeopardytest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy/mockserver"
+)
+
+// The scripted platform is the one package mockserver serves.
+type (
+	Platform  = mockserver.Platform
+	Challenge = mockserver.Challenge
+	File      = mockserver.File
+	Solve     = mockserver.Solve
+)
+
+// NewPlatform returns the platform the conformance suite runs against:
+// three challenges with numeric IDs, two of them with attachments, and the
+// first one already solved.
+func NewPlatform() *Platform {
+	p := &Platform{
+		Challenges: []Challenge{
+			{
+				ID: "1", Name: "warmup", Category: "misc", Points: 50,
+				Description: "The flag is flag{warmup}.",
+				Flag:        "flag{warmup}",
+			},
+			{
+				ID: "2", Name: "baby heap", Category: "pwn", Points: 400,
+				Description: "nc heap.example.org 31337",
+				Flag:        "flag{tcache}",
+				Files: []File{
+					{Name: "heap", Content: []byte("\x7fELF not really a binary\n")},
+					{Name: "libc.so.6", Content: []byte("not really a libc\n")},
+				},
+			},
+			{
+				ID: "3", Name: "small e", Category: "crypto", Points: 200,
+				Description: "Textbook RSA, what could go wrong?",
+				Flag:        "flag{cube_root}",
+				Files:       []File{{Name: "chall.py", Content: []byte("print(pow(m, 3, n))\n")}},
+			},
+		},
+		UserID:   7,
+		UserName: "tester",
+	}
+	p.MarkSolved("1", time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	return p
+}
+
+// CTFdHandler serves p through the CTFd API, for the ctfd_token and
+// ctfd_cookie backends.
+func CTFdHandler(p *Platform) http.Handler { return mockserver.CTFd(p) }
+
+// RCTFHandler serves p through the rCTF API, for the rctf backend.
+func RCTFHandler(p *Platform) http.Handler { return mockserver.RCTF(p) }
+
+// CCITHandler serves p through the CCIT API, for the ccit backend.
+func CCITHandler(p *Platform) http.Handler { return mockserver.CCIT(p) }
@@ -0,0 +1,375 @@
+package jeopardy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	Register(BackendDef{
+		ID:   "local",
+		Name: "Local Files",
+		Settings: []SettingDef{
+			{ID: "path", Name: "Challenge directory, or JSON/YAML file", Required: true},
+			{ID: "solves", Name: "Solves file (default next to the challenges)"},
+			{ID: "user", Name: "Name recorded with solves (default the login name)"},
+		},
+		Build: func(s map[string]string) (Backend, error) {
+			return newLocal(s["path"], s["solves"], s["user"])
+		},
+	})
+}
+
+// localChallengeFiles are the per-challenge files a directory tree is
+// scanned for; challenge.json is what get writes.
+var localChallengeFiles = []string{"challenge.json", "challenge.yaml", "challenge.yml"}
+
+// localClient serves challenges from disk: either a tree of directories
+// holding a challenge.json each, as written by get, or a single JSON/YAML
+// file listing them. Flags are checked against optional SHA-256 hashes and
+// solves are kept in a JSON file.
+type localClient struct {
+	path       string
+	solvesPath string
+	user       string
+
+	mu sync.Mutex
+}
+
+// localChallenge is a challenge on disk: the fields get writes to
+// challenge.json, plus an optional flag hash.
+type localChallenge struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Category    string   `json:"category"`
+	Description string   `json:"description"`
+	Points      int      `json:"points"`
+	Tags        []string `json:"tags"`
+	Hints       []string `json:"hints"`
+	Solved      bool     `json:"solved"`
+	Files       []struct {
+		Name string `json:"name"`
+		Path string `json:"path"`
+	} `json:"files"`
+	// FlagSHA256 is the hex SHA-256 of the flag, so archives can be
+	// replayed as practice without storing flags in the clear.
+	FlagSHA256 string `json:"flag_sha256"`
+
+	dir string // attachments are relative to it
+}
+
+type localSolve struct {
+	ChallengeID string    `json:"challenge_id"`
+	SolvedAt    time.Time `json:"solved_at"`
+	UserName    string    `json:"user_name,omitempty"`
+	Points      int       `json:"points,omitempty"`
+}
+
+type localFile struct {
+	name string
+	path string
+}
+
+func (f *localFile) Name() string { return f.name }
+
+func (f *localFile) DownloadURL(ctx context.Context) (*DownloadInfo, error) {
+	return &DownloadInfo{URL: (&url.URL{Scheme: "file", Path: filepath.ToSlash(f.path)}).String()}, nil
+}
+
+func (f *localFile) Open(ctx context.Context) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return os.Open(f.path)
+}
+
+func newLocal(path, solvesPath, userName string) (*localClient, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if solvesPath == "" {
+		if info.IsDir() {
+			solvesPath = filepath.Join(abs, ".ctf-sync-solves.json")
+		} else {
+			solvesPath = strings.TrimSuffix(abs, filepath.Ext(abs)) + ".solves.json"
+		}
+	}
+	if userName == "" {
+		if u, err := user.Current(); err == nil {
+			userName = u.Username
+		}
+	}
+	return &localClient{path: abs, solvesPath: solvesPath, user: userName}, nil
+}
+
+func (c *localClient) Fetch(ctx context.Context) ([]Challenge, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	locals, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	solves, err := c.loadSolves()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Challenge, 0, len(locals))
+	for _, lc := range locals {
+		chal := Challenge{
+			ID:       lc.ID,
+			Name:     lc.Name,
+			Category: lc.Category,
+			Points:   lc.Points,
+			Tags:     lc.Tags,
+			Hints:    lc.Hints,
+			Solved:   lc.Solved || slices.ContainsFunc(solves, func(s localSolve) bool { return s.ChallengeID == lc.ID }),
+		}
+		chal.SetDescription(lc.Description)
+		for _, f := range lc.Files {
+			path, err := localFilePath(lc.dir, nonEmpty(f.Path, f.Name))
+			if err != nil {
+				return nil, fmt.Errorf("challenge %s: %w", lc.ID, err)
+			}
+			if _, err := os.Stat(path); err != nil {
+				// get records failed downloads too; there is nothing to serve.
+				continue
+			}
+			chal.Files = append(chal.Files, &localFile{name: nonEmpty(f.Name, filepath.Base(path)), path: path})
+		}
+		results = append(results, chal)
+	}
+	return results, nil
+}
+
+// localFilePath resolves an attachment path against dir. Challenge files get
+// shared, so like names from a platform, paths aren't trusted: one leaving
+// dir, through "..", an absolute path or a symlink, would serve any local
+// file (~/.ssh/id_rsa) as an attachment.
+func localFilePath(dir, name string) (string, error) {
+	rel := filepath.FromSlash(name)
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("file %q is outside %s", name, dir)
+	}
+	path := filepath.Join(dir, rel)
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		// Missing files are skipped by the caller.
+		return path, nil
+	}
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(root, resolved); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("file %q links outside %s", name, dir)
+	}
+	return path, nil
+}
+
+func (c *localClient) Submit(ctx context.Context, challengeID, flag string) (*SubmitResult, error) {
+	if flag == "" {
+		return nil, fmt.Errorf("flag is required")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	locals, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(locals, func(lc localChallenge) bool { return lc.ID == challengeID })
+	if i < 0 {
+		return nil, fmt.Errorf("challenge %s not found", challengeID)
+	}
+	lc := locals[i]
+	if lc.FlagSHA256 == "" {
+		return &SubmitResult{Status: Pending, Message: "no flag hash stored for this challenge, can't check"}, nil
+	}
+	sum := sha256.Sum256([]byte(strings.TrimSpace(flag)))
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), strings.TrimSpace(lc.FlagSHA256)) {
+		return &SubmitResult{Status: Rejected, Message: "Incorrect"}, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	solves, err := c.loadSolves()
+	if err != nil {
+		return nil, err
+	}
+	if lc.Solved || slices.ContainsFunc(solves, func(s localSolve) bool { return s.ChallengeID == lc.ID }) {
+		return &SubmitResult{Status: Duplicate, Message: "Already solved"}, nil
+	}
+	solves = append(solves, localSolve{
+		ChallengeID: lc.ID,
+		SolvedAt:    time.Now().UTC().Truncate(time.Second),
+		UserName:    c.user,
+		Points:      lc.Points,
+	})
+	data, err := json.MarshalIndent(struct {
+		Solves []localSolve `json:"solves"`
+	}{solves}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(c.solvesPath, append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("save solve: %w", err)
+	}
+	return &SubmitResult{Status: Accepted, Message: "Correct"}, nil
+}
+
+// Solves returns the recorded solves, plus those marked solved in the
+// challenge files (with no time, since get doesn't record one).
+func (c *localClient) Solves(ctx context.Context) ([]Solve, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	locals, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	solves, err := c.loadSolves()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Solve
+	for _, s := range solves {
+		solvedAt := s.SolvedAt
+		results = append(results, Solve{ChallengeID: s.ChallengeID, SolvedAt: &solvedAt, UserName: s.UserName, Points: s.Points})
+	}
+	for _, lc := range locals {
+		if lc.Solved && !slices.ContainsFunc(solves, func(s localSolve) bool { return s.ChallengeID == lc.ID }) {
+			results = append(results, Solve{ChallengeID: lc.ID, Points: lc.Points})
+		}
+	}
+	return results, nil
+}
+
+// load reads the challenges, from a file or by walking the directory.
+func (c *localClient) load() ([]localChallenge, error) {
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return loadLocalList(c.path)
+	}
+
+	var locals []localChallenge
+	err = filepath.WalkDir(c.path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != c.path && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		for _, name := range localChallengeFiles {
+			var lc localChallenge
+			if err := readLocalFile(filepath.Join(path, name), &lc); errors.Is(err, fs.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(c.path, path)
+			lc.ID = nonEmpty(lc.ID, filepath.ToSlash(rel))
+			lc.Name = nonEmpty(lc.Name, d.Name())
+			lc.dir = path
+			locals = append(locals, lc)
+			return nil
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return locals, checkLocalIDs(locals)
+}
+
+// loadLocalList reads a file holding {"challenges": [...]} or a bare list.
+func loadLocalList(path string) ([]localChallenge, error) {
+	var list struct {
+		Challenges []localChallenge `json:"challenges"`
+	}
+	if err := readLocalFile(path, &list); err != nil {
+		var bare []localChallenge
+		if readLocalFile(path, &bare) != nil {
+			return nil, err
+		}
+		list.Challenges = bare
+	}
+	for i := range list.Challenges {
+		lc := &list.Challenges[i]
+		lc.ID = nonEmpty(lc.ID, strconv.Itoa(i+1))
+		lc.dir = filepath.Dir(path)
+	}
+	return list.Challenges, checkLocalIDs(list.Challenges)
+}
+
+func checkLocalIDs(locals []localChallenge) error {
+	seen := make(map[string]bool)
+	for _, lc := range locals {
+		if seen[lc.ID] {
+			return fmt.Errorf("duplicate challenge id %q", lc.ID)
+		}
+		seen[lc.ID] = true
+	}
+	return nil
+}
+
+// readLocalFile decodes JSON, or YAML by extension, into v's JSON fields.
+func readLocalFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		var doc any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *localClient) loadSolves() ([]localSolve, error) {
+	var saved struct {
+		Solves []localSolve `json:"solves"`
+	}
+	if err := readLocalFile(c.solvesPath, &saved); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return saved.Solves, nil
+}
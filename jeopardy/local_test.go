@@ -0,0 +1,167 @@
+package jeopardy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func flagHash(flag string) string {
+	sum := sha256.Sum256([]byte(flag))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestLocalDirectory(t *testing.T) {
+	dir := t.TempDir()
+	// As written by get, with one download that failed.
+	writeTestFile(t, filepath.Join(dir, "pwn", "baby_heap", "challenge.json"), `{
+  "id": "42",
+  "name": "baby heap",
+  "category": "pwn",
+  "description": "nc heap.example.org 31337",
+  "points": 400,
+  "tags": ["heap"],
+  "files": [{"name": "heap", "path": "heap", "size": 3}, {"name": "libc.so.6", "path": "libc.so.6"}],
+  "solved": false,
+  "flag_sha256": "`+flagHash("flag{tcache}")+`"
+}`)
+	writeTestFile(t, filepath.Join(dir, "pwn", "baby_heap", "heap"), "ELF")
+	writeTestFile(t, filepath.Join(dir, "misc", "warmup", "challenge.yaml"), "name: warmup\ncategory: misc\npoints: 50\nsolved: true\n")
+	writeTestFile(t, filepath.Join(dir, ".git", "x", "challenge.json"), `{"name": "ignored"}`)
+
+	b, err := Build("local", map[string]string{"path": dir, "user": "alice"})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	ctx := context.Background()
+	challenges, err := b.Fetch(ctx)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if len(challenges) != 2 {
+		t.Fatalf("got %d challenges, want 2: %+v", len(challenges), challenges)
+	}
+	warmup, heap := challenges[0], challenges[1]
+	if warmup.ID != "misc/warmup" || !warmup.Solved || warmup.Points != 50 {
+		t.Errorf("warmup = %+v", warmup)
+	}
+	if heap.ID != "42" || heap.Solved || len(heap.Endpoints) != 1 || len(heap.Files) != 1 {
+		t.Fatalf("heap = %+v", heap)
+	}
+	r, err := OpenFile(ctx, heap.Files[0])
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "ELF" {
+		t.Errorf("read %q", data)
+	}
+
+	for _, tc := range []struct {
+		id, flag string
+		want     SubmitStatus
+	}{
+		{"42", "flag{nope}", Rejected},
+		{"42", "flag{tcache}\n", Accepted},
+		{"42", "flag{tcache}", Duplicate},
+		{"misc/warmup", "flag{anything}", Pending},
+	} {
+		res, err := b.Submit(ctx, tc.id, tc.flag)
+		if err != nil {
+			t.Fatalf("Submit(%s, %q) failed: %v", tc.id, tc.flag, err)
+		}
+		if res.Status != tc.want {
+			t.Errorf("Submit(%s, %q) = %s, want %s", tc.id, tc.flag, res.Status, tc.want)
+		}
+	}
+
+	// Solves persist across instances.
+	b, _ = Build("local", map[string]string{"path": dir})
+	solves, err := b.Solves(ctx)
+	if err != nil {
+		t.Fatalf("Solves failed: %v", err)
+	}
+	if len(solves) != 2 || solves[0].ChallengeID != "42" || solves[0].UserName != "alice" || solves[0].SolvedAt == nil || solves[1].ChallengeID != "misc/warmup" {
+		t.Errorf("solves = %+v", solves)
+	}
+	if challenges, _ := b.Fetch(ctx); !challenges[1].Solved {
+		t.Errorf("solved challenge not marked solved")
+	}
+}
+
+func TestLocalFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ctf.yaml")
+	writeTestFile(t, path, `
+challenges:
+  - name: sanity
+    category: misc
+    points: 1
+  - id: xor
+    name: xorrox
+    category: crypto
+    points: 150
+    files:
+      - name: xorrox.py
+        path: handouts/xorrox.py
+`)
+	writeTestFile(t, filepath.Join(dir, "handouts", "xorrox.py"), "print()")
+
+	b, err := Build("local", map[string]string{"path": path})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	challenges, err := b.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if len(challenges) != 2 || challenges[0].ID != "1" || challenges[1].ID != "xor" || len(challenges[1].Files) != 1 {
+		t.Fatalf("unexpected challenges: %+v", challenges)
+	}
+
+	if _, err := Build("local", map[string]string{"path": filepath.Join(dir, "missing.json")}); err == nil {
+		t.Error("Build accepted a missing path")
+	}
+}
+
+func TestLocalFileOutsideDirectory(t *testing.T) {
+	outside := t.TempDir()
+	writeTestFile(t, filepath.Join(outside, "id_rsa"), "secret")
+
+	for name, file := range map[string]string{
+		"dotdot":   "../" + filepath.Base(outside) + "/id_rsa",
+		"absolute": filepath.ToSlash(filepath.Join(outside, "id_rsa")),
+		"symlink":  "link",
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.Symlink(filepath.Join(outside, "id_rsa"), filepath.Join(dir, "link")); err != nil {
+				t.Skipf("can't create symlinks: %v", err)
+			}
+			path := filepath.Join(dir, "ctf.yaml")
+			writeTestFile(t, path, "challenges:\n  - name: practice\n    files:\n      - name: key\n        path: "+file+"\n")
+			b, err := Build("local", map[string]string{"path": path})
+			if err != nil {
+				t.Fatalf("Build failed: %v", err)
+			}
+			if challenges, err := b.Fetch(context.Background()); err == nil {
+				t.Errorf("Fetch served %s: %+v", file, challenges)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package jeopardy
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// ErrReadOnly is returned by Submit on a backend wrapped with ReadOnly.
+var ErrReadOnly = errors.New("read-only: submissions are disabled")
+
+// Middleware wraps a Backend to add behavior around its calls.
+type Middleware func(Backend) Backend
+
+// Wrap applies mw to b. The first middleware is the outermost, so
+// Wrap(b, ReadOnly(), Logging(l)) rejects submissions before they are
+// logged, and middlewares after (*Cache).Wrap only see the calls that
+// reach the platform.
+func Wrap(b Backend, mw ...Middleware) Backend {
+	for i := len(mw) - 1; i >= 0; i-- {
+		b = mw[i](b)
+	}
+	return b
+}
+
+// Wrap installs c around b; use it as a Middleware.
+func (c *Cache) Wrap(b Backend) Backend {
+	c.Backend = b
+	return c
+}
+
+// Close closes the cached backend.
+func (c *Cache) Close() error {
+	return Close(c.Backend)
+}
+
+// fetchIfChanged uses b's ConditionalFetcher when it has one, and falls
+// back to a plain Fetch.
+func fetchIfChanged(ctx context.Context, b Backend, v Validators) ([]Challenge, Validators, error) {
+	if cf, ok := b.(ConditionalFetcher); ok {
+		return cf.FetchIfChanged(ctx, v)
+	}
+	challenges, err := b.Fetch(ctx)
+	return challenges, Validators{}, err
+}
+
+// wrapped is embedded by the built-in middlewares. It keeps the wrapped
+// backend's ConditionalFetcher usable from the outside, and its Close.
+type wrapped struct {
+	Backend
+}
+
+func (w wrapped) FetchIfChanged(ctx context.Context, v Validators) ([]Challenge, Validators, error) {
+	return fetchIfChanged(ctx, w.Backend, v)
+}
+
+func (w wrapped) Close() error {
+	return Close(w.Backend)
+}
+
+// ReadOnly makes Submit fail with ErrReadOnly.
+func ReadOnly() Middleware {
+	return func(b Backend) Backend {
+		return readOnlyBackend{wrapped{b}}
+	}
+}
+
+type readOnlyBackend struct {
+	wrapped
+}
+
+func (readOnlyBackend) Submit(ctx context.Context, challengeID, flag string) (*SubmitResult, error) {
+	return nil, ErrReadOnly
+}
+
+// DryRun answers Submit with a Pending result without contacting the
+// platform, for trying out scripts and automation.
+func DryRun() Middleware {
+	return func(b Backend) Backend {
+		return dryRunBackend{wrapped{b}}
+	}
+}
+
+type dryRunBackend struct {
+	wrapped
+}
+
+func (dryRunBackend) Submit(ctx context.Context, challengeID, flag string) (*SubmitResult, error) {
+	return &SubmitResult{Status: Pending, Message: "dry run: flag not submitted"}, nil
+}
+
+// Timing calls record after every backend call with the operation name
+// ("fetch", "submit" or "solves"), its duration and its error.
+func Timing(record func(op string, d time.Duration, err error)) Middleware {
+	return func(b Backend) Backend {
+		return &timingBackend{wrapped: wrapped{b}, record: record}
+	}
+}
+
+type timingBackend struct {
+	wrapped
+	record func(op string, d time.Duration, err error)
+}
+
+func (b *timingBackend) Fetch(ctx context.Context) ([]Challenge, error) {
+	start := time.Now()
+	challenges, err := b.Backend.Fetch(ctx)
+	b.record("fetch", time.Since(start), err)
+	return challenges, err
+}
+
+func (b *timingBackend) FetchIfChanged(ctx context.Context, v Validators) ([]Challenge, Validators, error) {
+	start := time.Now()
+	challenges, v, err := fetchIfChanged(ctx, b.Backend, v)
+	if errors.Is(err, ErrNotModified) {
+		b.record("fetch", time.Since(start), nil)
+	} else {
+		b.record("fetch", time.Since(start), err)
+	}
+	return challenges, v, err
+}
+
+func (b *timingBackend) Submit(ctx context.Context, challengeID, flag string) (*SubmitResult, error) {
+	start := time.Now()
+	result, err := b.Backend.Submit(ctx, challengeID, flag)
+	b.record("submit", time.Since(start), err)
+	return result, err
+}
+
+func (b *timingBackend) Solves(ctx context.Context) ([]Solve, error) {
+	start := time.Now()
+	solves, err := b.Backend.Solves(ctx)
+	b.record("solves", time.Since(start), err)
+	return solves, err
+}
+
+// Logging logs every backend call to logger, at Info level, or Error when
+// the call fails. Flags are never logged.
+func Logging(logger *slog.Logger) Middleware {
+	return func(b Backend) Backend {
+		return &loggingBackend{wrapped: wrapped{b}, log: logger}
+	}
+}
+
+type loggingBackend struct {
+	wrapped
+	log *slog.Logger
+}
+
+func (b *loggingBackend) done(ctx context.Context, op string, start time.Time, err error, attrs ...any) {
+	attrs = append(attrs, slog.Duration("duration", time.Since(start)))
+	if err != nil {
+		b.log.ErrorContext(ctx, op+" failed", append(attrs, slog.Any("err", err))...)
+		return
+	}
+	b.log.InfoContext(ctx, op, attrs...)
+}
+
+func (b *loggingBackend) Fetch(ctx context.Context) ([]Challenge, error) {
+	start := time.Now()
+	challenges, err := b.Backend.Fetch(ctx)
+	b.done(ctx, "fetch", start, err, slog.Int("challenges", len(challenges)))
+	return challenges, err
+}
+
+func (b *loggingBackend) FetchIfChanged(ctx context.Context, v Validators) ([]Challenge, Validators, error) {
+	start := time.Now()
+	challenges, v, err := fetchIfChanged(ctx, b.Backend, v)
+	if errors.Is(err, ErrNotModified) {
+		b.done(ctx, "fetch", start, nil, slog.Bool("not_modified", true))
+		return challenges, v, err
+	}
+	b.done(ctx, "fetch", start, err, slog.Int("challenges", len(challenges)))
+	return challenges, v, err
+}
+
+func (b *loggingBackend) Submit(ctx context.Context, challengeID, flag string) (*SubmitResult, error) {
+	start := time.Now()
+	result, err := b.Backend.Submit(ctx, challengeID, flag)
+	attrs := []any{slog.String("challenge", challengeID)}
+	if result != nil {
+		attrs = append(attrs, slog.String("status", string(result.Status)))
+	}
+	b.done(ctx, "submit", start, err, attrs...)
+	return result, err
+}
+
+func (b *loggingBackend) Solves(ctx context.Context) ([]Solve, error) {
+	start := time.Now()
+	solves, err := b.Backend.Solves(ctx)
+	b.done(ctx, "solves", start, err, slog.Int("solves", len(solves)))
+	return solves, err
+}
@@ -0,0 +1,114 @@
+package jeopardy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrapOrder(t *testing.T) {
+	var calls []string
+	tag := func(name string) Middleware {
+		return Timing(func(op string, d time.Duration, err error) {
+			calls = append(calls, name+":"+op)
+		})
+	}
+	b := Wrap(&countingBackend{}, tag("outer"), tag("inner"))
+	if _, err := b.Fetch(context.Background()); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	// Timing records after the call returns, so the inner one reports first.
+	if got := strings.Join(calls, ","); got != "inner:fetch,outer:fetch" {
+		t.Errorf("calls = %s", got)
+	}
+}
+
+func TestReadOnlyAndDryRun(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingBackend{}
+
+	if _, err := Wrap(inner, ReadOnly()).Submit(ctx, "1", "FLAG{x}"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("read-only Submit error = %v, want ErrReadOnly", err)
+	}
+	result, err := Wrap(inner, DryRun()).Submit(ctx, "1", "FLAG{x}")
+	if err != nil || result.Status != Pending {
+		t.Errorf("dry-run Submit = %+v, %v", result, err)
+	}
+	if inner.submits != 0 {
+		t.Errorf("backend saw %d submissions, want 0", inner.submits)
+	}
+}
+
+func TestLoggingOmitsFlag(t *testing.T) {
+	var buf bytes.Buffer
+	b := Wrap(&countingBackend{}, Logging(slog.New(slog.NewTextHandler(&buf, nil))))
+	if _, err := b.Submit(context.Background(), "7", "FLAG{secret}"); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "challenge=7") || !strings.Contains(out, "status=accepted") {
+		t.Errorf("log missing fields: %s", out)
+	}
+	if strings.Contains(out, "secret") {
+		t.Errorf("log contains the flag: %s", out)
+	}
+}
+
+// conditionalBackend reports every fetch after the first as unchanged.
+type conditionalBackend struct {
+	countingBackend
+}
+
+func (b *conditionalBackend) FetchIfChanged(ctx context.Context, v Validators) ([]Challenge, Validators, error) {
+	if v.ETag == `"seen"` {
+		return nil, v, ErrNotModified
+	}
+	challenges, err := b.Fetch(ctx)
+	return challenges, Validators{ETag: `"seen"`}, err
+}
+
+func TestMiddlewareKeepsRevalidation(t *testing.T) {
+	inner := &conditionalBackend{countingBackend{challenges: []Challenge{{ID: "1"}}}}
+	cache := &Cache{Path: filepath.Join(t.TempDir(), "cache.json")}
+	b := Wrap(inner, cache.Wrap, Logging(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))))
+	for i := 0; i < 3; i++ {
+		got, err := b.Fetch(context.Background())
+		if err != nil || len(got) != 1 {
+			t.Fatalf("Fetch = %v, %v", got, err)
+		}
+	}
+	if inner.fetches != 1 {
+		t.Errorf("backend fetched %d times, want 1", inner.fetches)
+	}
+}
+
+type closingBackend struct {
+	countingBackend
+	closed int
+}
+
+func (b *closingBackend) Close() error {
+	b.closed++
+	return nil
+}
+
+func TestCloseReachesBackends(t *testing.T) {
+	a, b := &closingBackend{}, &closingBackend{}
+	agg, err := NewAggregate(AggregateMember{Name: "a", Backend: a}, AggregateMember{Name: "b", Backend: b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := &Cache{Path: filepath.Join(t.TempDir(), "cache.json")}
+	wrapped := Wrap(agg, ReadOnly(), cache.Wrap, Logging(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))))
+	if err := Close(wrapped); err != nil || a.closed != 1 || b.closed != 1 {
+		t.Errorf("Close = %v, closed %d and %d times", err, a.closed, b.closed)
+	}
+	if err := Close(&countingBackend{}); err != nil {
+		t.Errorf("Close of a backend without Close = %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy"
+)
+
+// ccitFilesToken is the files token the CCIT server hands out.
+const ccitFilesToken = "mock-files-token"
+
+// CCIT serves p through the CCIT platform API, with one event whose
+// sections are the challenge categories. Platform.Token is the API token;
+// attachments need the files token as ?auth=.
+func CCIT(p *Platform) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/currentUser", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"id": p.UserID, "username": p.UserName, "filesToken": ccitFilesToken})
+	})
+	mux.HandleFunc("GET /api/challenges", func(w http.ResponseWriter, r *http.Request) {
+		var sections []map[string]any
+		index := map[string]int{}
+		for _, c := range p.Challenges {
+			i, ok := index[c.Category]
+			if !ok {
+				i = len(sections)
+				index[c.Category] = i
+				sections = append(sections, map[string]any{"id": i + 1, "name": c.Category, "challenges": []map[string]any{}})
+			}
+			id, _ := strconv.Atoi(c.ID)
+			sections[i]["challenges"] = append(sections[i]["challenges"].([]map[string]any), map[string]any{"id": id, "title": c.Name})
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"events": []map[string]any{{"id": 1, "name": "Mock", "sections": sections}}})
+	})
+	mux.HandleFunc("GET /api/challenges/{id}", func(w http.ResponseWriter, r *http.Request) {
+		c := p.Challenge(r.PathValue("id"))
+		if c == nil {
+			writeJSON(w, http.StatusNotFound, map[string]any{"message": "not found"})
+			return
+		}
+		files := make([]map[string]any, 0, len(c.Files))
+		for i, f := range c.Files {
+			files = append(files, map[string]any{"name": f.Name, "url": fmt.Sprintf("/api/challenges/%s/files/%d", c.ID, i)})
+		}
+		id, _ := strconv.Atoi(c.ID)
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":          id,
+			"title":       c.Name,
+			"description": c.Description,
+			"points":      c.Points,
+			"completed":   p.Solved(c.ID),
+			"files":       files,
+			"tags":        []string{},
+		})
+	})
+	mux.HandleFunc("GET /api/challenges/{id}/files/{n}", func(w http.ResponseWriter, r *http.Request) {
+		c := p.Challenge(r.PathValue("id"))
+		n, err := strconv.Atoi(r.PathValue("n"))
+		if c == nil || err != nil || n < 0 || n >= len(c.Files) {
+			http.Error(w, "file not found", http.StatusNotFound)
+			return
+		}
+		serveFile(w, p, c.ID, c.Files[n].Name)
+	})
+	mux.HandleFunc("POST /api/challenges/{id}/flag", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Flag string `json:"flag"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch p.Submit(r.PathValue("id"), req.Flag) {
+		case jeopardy.Accepted:
+			writeJSON(w, http.StatusOK, map[string]any{"valid": true, "message": "Correct!"})
+		default:
+			writeJSON(w, http.StatusOK, map[string]any{"valid": false, "message": "Wrong flag"})
+		}
+	})
+	mux.HandleFunc("GET /api/player/unlocks", func(w http.ResponseWriter, r *http.Request) {
+		solves := []int{}
+		for _, s := range p.Solves() {
+			id, _ := strconv.Atoi(s.ChallengeID)
+			solves = append(solves, id)
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"solves": solves})
+	})
+
+	return serve(p, mux, func(r *http.Request) bool {
+		if strings.Contains(r.URL.Path, "/files/") {
+			return r.URL.Query().Get("auth") == ccitFilesToken
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Token ")
+		return ok && p.authorized(token)
+	})
+}
@@ -0,0 +1,124 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy"
+)
+
+// CTFd serves p through the parts of the CTFd API the ctfd_token and
+// ctfd_cookie backends use, in user mode. Platform.Token is accepted as an
+// API token or as the session cookie.
+func CTFd(p *Platform) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/challenges", func(w http.ResponseWriter, r *http.Request) {
+		type summary struct {
+			ID         int    `json:"id"`
+			Name       string `json:"name"`
+			Category   string `json:"category"`
+			Value      int    `json:"value"`
+			SolvedByMe bool   `json:"solved_by_me"`
+		}
+		data := make([]summary, 0, len(p.Challenges))
+		for _, c := range p.Challenges {
+			id, _ := strconv.Atoi(c.ID)
+			data = append(data, summary{id, c.Name, c.Category, c.Points, p.Solved(c.ID)})
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"success": true,
+			"data":    data,
+			"meta":    map[string]any{"pagination": map[string]any{"page": 1, "next": nil, "pages": 1, "per_page": 50, "total": len(data)}},
+		})
+	})
+	mux.HandleFunc("GET /api/v1/challenges/{id}", func(w http.ResponseWriter, r *http.Request) {
+		c := p.Challenge(r.PathValue("id"))
+		if c == nil {
+			writeJSON(w, http.StatusNotFound, map[string]any{"message": "Challenge not found"})
+			return
+		}
+		files := make([]string, 0, len(c.Files))
+		for _, f := range c.Files {
+			files = append(files, fmt.Sprintf("/files/%s/%s?token=signed", c.ID, f.Name))
+		}
+		id, _ := strconv.Atoi(c.ID)
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "data": map[string]any{
+			"id":              id,
+			"name":            c.Name,
+			"category":        c.Category,
+			"description":     c.Description,
+			"connection_info": nil,
+			"value":           c.Points,
+			"files":           files,
+		}})
+	})
+	mux.HandleFunc("GET /files/{id}/{name}", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("token") == "" {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		serveFile(w, p, r.PathValue("id"), r.PathValue("name"))
+	})
+	mux.HandleFunc("POST /api/v1/challenges/attempt", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ChallengeID int    `json:"challenge_id"`
+			Submission  string `json:"submission"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
+			return
+		}
+		code, status, message := http.StatusOK, "incorrect", "Incorrect"
+		switch p.Submit(strconv.Itoa(req.ChallengeID), req.Submission) {
+		case jeopardy.Accepted:
+			status, message = "correct", "Correct"
+		case jeopardy.Duplicate:
+			status, message = "already_solved", "You already solved this"
+		case jeopardy.RateLimited:
+			code, status, message = http.StatusTooManyRequests, "ratelimited", "You're submitting flags too fast. Slow down."
+		case jeopardy.Pending:
+			status, message = "queued", "Submission received"
+		case jeopardy.Error:
+			code, status, message = http.StatusForbidden, "paused", "CTF is paused"
+		}
+		writeJSON(w, code, map[string]any{"success": true, "data": map[string]any{"status": status, "message": message}})
+	})
+	// Like most instances, the config is admin-only and /teams/me answers
+	// 404 in user mode.
+	mux.HandleFunc("GET /api/v1/configs/user_mode", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusForbidden, map[string]any{"message": "Forbidden"})
+	})
+	mux.HandleFunc("GET /api/v1/teams/me", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, map[string]any{"message": "Not found"})
+	})
+	mux.HandleFunc("GET /api/v1/users/me/solves", func(w http.ResponseWriter, r *http.Request) {
+		var data []map[string]any
+		for _, s := range p.Solves() {
+			id, _ := strconv.Atoi(s.ChallengeID)
+			points := 0
+			if c := p.Challenge(s.ChallengeID); c != nil {
+				points = c.Points
+			}
+			data = append(data, map[string]any{
+				"challenge_id": id,
+				"date":         s.At.Format(time.RFC3339),
+				"challenge":    map[string]any{"id": id, "value": points},
+				"user":         map[string]any{"id": p.UserID, "name": p.UserName},
+				"type":         "correct",
+			})
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "data": data})
+	})
+
+	return serve(p, mux, func(r *http.Request) bool {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Token "); ok {
+			return p.authorized(token)
+		}
+		session, err := r.Cookie("session")
+		return err == nil && p.authorized(session.Value)
+	})
+}
@@ -0,0 +1,166 @@
+// Package mockserver serves a local, fake CTF platform speaking the CTFd,
+// rCTF or CCIT API, for developing against ctf-sync without a live event.
+//
+// The platform is defined in YAML:
+//
+//	user: tester
+//	token: secret           # credential to require; empty accepts any
+//	rate_limit:
+//	  attempts: 5           # flag submissions allowed...
+//	  per: 1m               # ...per window
+//	challenges:
+//	  - name: warmup
+//	    category: misc
+//	    points: 50
+//	    description: The flag is flag{warmup}.
+//	    flag: flag{warmup}
+//	    solved: true
+//	  - id: "2"             # default: the position in the list, from 1
+//	    name: baby heap
+//	    category: pwn
+//	    points: 400
+//	    description: nc localhost 31337
+//	    flag: flag{tcache}
+//	    files:
+//	      - path: heap      # relative to the YAML file
+//	      - name: notes.txt
+//	        content: inline content
+//
+// and served with
+//
+//	p, err := mockserver.Load("ctf.yaml")
+//	h, err := mockserver.Handler("ctfd", p)
+//	http.ListenAndServe("localhost:8000", h)
+package mockserver
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// APIs lists the platform APIs Handler can serve.
+var APIs = []string{"ctfd", "rctf", "ccit"}
+
+type config struct {
+	User      string `yaml:"user"`
+	Token     string `yaml:"token"`
+	RateLimit struct {
+		Attempts int           `yaml:"attempts"`
+		Per      time.Duration `yaml:"per"`
+	} `yaml:"rate_limit"`
+	Challenges []struct {
+		ID          string `yaml:"id"`
+		Name        string `yaml:"name"`
+		Category    string `yaml:"category"`
+		Description string `yaml:"description"`
+		Points      int    `yaml:"points"`
+		Flag        string `yaml:"flag"`
+		Solved      bool   `yaml:"solved"`
+		Files       []struct {
+			Name    string `yaml:"name"`
+			Path    string `yaml:"path"`
+			Content string `yaml:"content"`
+		} `yaml:"files"`
+	} `yaml:"challenges"`
+}
+
+// Load reads a platform definition. Attachment paths are relative to the
+// file's directory.
+func Load(path string) (*Platform, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	p := &Platform{
+		UserID:     1,
+		UserName:   cfg.User,
+		Token:      cfg.Token,
+		RateLimit:  cfg.RateLimit.Attempts,
+		RateWindow: cfg.RateLimit.Per,
+	}
+	if p.UserName == "" {
+		p.UserName = "player"
+	}
+	if p.RateLimit > 0 && p.RateWindow <= 0 {
+		p.RateWindow = time.Minute
+	}
+
+	dir := filepath.Dir(path)
+	seen := make(map[string]bool)
+	var solved []string
+	for i, cc := range cfg.Challenges {
+		c := Challenge{
+			ID:          cc.ID,
+			Name:        cc.Name,
+			Category:    cc.Category,
+			Description: cc.Description,
+			Points:      cc.Points,
+			Flag:        cc.Flag,
+		}
+		if c.ID == "" {
+			c.ID = strconv.Itoa(i + 1)
+		}
+		if c.Name == "" {
+			return nil, fmt.Errorf("%s: challenge %s has no name", path, c.ID)
+		}
+		if seen[c.ID] {
+			return nil, fmt.Errorf("%s: duplicate challenge id %s", path, c.ID)
+		}
+		seen[c.ID] = true
+
+		for _, cf := range cc.Files {
+			f := File{Name: cf.Name, Content: []byte(cf.Content)}
+			if cf.Path != "" {
+				if f.Content, err = os.ReadFile(filepath.Join(dir, cf.Path)); err != nil {
+					return nil, fmt.Errorf("challenge %s: %w", c.Name, err)
+				}
+				if f.Name == "" {
+					f.Name = filepath.Base(cf.Path)
+				}
+			}
+			if f.Name == "" {
+				return nil, fmt.Errorf("%s: challenge %s has a file without a name or path", path, c.Name)
+			}
+			c.Files = append(c.Files, f)
+		}
+		p.Challenges = append(p.Challenges, c)
+		if cc.Solved {
+			solved = append(solved, c.ID)
+		}
+	}
+	for _, id := range solved {
+		p.MarkSolved(id, time.Now())
+	}
+	return p, nil
+}
+
+// Handler serves p through the named API, one of APIs. CTFd and CCIT
+// need numeric challenge IDs.
+func Handler(api string, p *Platform) (http.Handler, error) {
+	switch api {
+	case "ctfd", "ccit":
+		for _, c := range p.Challenges {
+			if _, err := strconv.Atoi(c.ID); err != nil {
+				return nil, fmt.Errorf("%s needs numeric challenge ids, not %q", api, c.ID)
+			}
+		}
+		if api == "ctfd" {
+			return CTFd(p), nil
+		}
+		return CCIT(p), nil
+	case "rctf":
+		return RCTF(p), nil
+	}
+	return nil, fmt.Errorf("unknown api %q (want one of %v)", api, APIs)
+}
@@ -0,0 +1,144 @@
+package mockserver_test
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy"
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy/mockserver"
+)
+
+const testYAML = `
+user: alice
+token: s3cret
+rate_limit:
+  attempts: 2
+  per: 1h
+challenges:
+  - name: warmup
+    category: misc
+    points: 50
+    flag: flag{warmup}
+    solved: true
+  - name: baby heap
+    category: pwn
+    points: 400
+    flag: flag{tcache}
+    files:
+      - path: heap
+      - name: notes.txt
+        content: use after free
+`
+
+func loadTest(t *testing.T) *mockserver.Platform {
+	t.Helper()
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "heap"), []byte("ELF"), 0644)
+	path := filepath.Join(dir, "ctf.yaml")
+	os.WriteFile(path, []byte(testYAML), 0644)
+	p, err := mockserver.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	return p
+}
+
+func TestLoad(t *testing.T) {
+	p := loadTest(t)
+	if len(p.Challenges) != 2 || p.Challenges[1].ID != "2" || p.UserName != "alice" {
+		t.Fatalf("unexpected platform: %+v", p)
+	}
+	if content, ok := p.File("2", "heap"); !ok || string(content) != "ELF" {
+		t.Errorf("file from path = %q, %v", content, ok)
+	}
+	if content, ok := p.File("2", "notes.txt"); !ok || string(content) != "use after free" {
+		t.Errorf("inline file = %q, %v", content, ok)
+	}
+	if !p.Solved("1") || p.Solved("2") {
+		t.Errorf("solved state not loaded")
+	}
+}
+
+func TestServe(t *testing.T) {
+	for _, tc := range []struct {
+		api, backend string
+		settings     map[string]string
+	}{
+		{"ctfd", "ctfd_token", map[string]string{"token": "s3cret"}},
+		{"rctf", "rctf", map[string]string{"team_token": "s3cret"}},
+		{"ccit", "ccit", map[string]string{"token": "s3cret", "x-version": "v5"}},
+	} {
+		t.Run(tc.api, func(t *testing.T) {
+			h, err := mockserver.Handler(tc.api, loadTest(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := httptest.NewServer(h)
+			defer srv.Close()
+			ctx := context.Background()
+
+			tc.settings["base_url"] = srv.URL
+			b, err := jeopardy.Build(tc.backend, tc.settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+			challenges, err := b.Fetch(ctx)
+			if err != nil {
+				t.Fatalf("Fetch failed: %v", err)
+			}
+			if len(challenges) != 2 || len(challenges[1].Files) != 2 {
+				t.Fatalf("unexpected challenges: %+v", challenges)
+			}
+			r, err := jeopardy.OpenFile(ctx, challenges[1].Files[1])
+			if err != nil {
+				t.Fatalf("OpenFile failed: %v", err)
+			}
+			data, _ := io.ReadAll(r)
+			r.Close()
+			if string(data) != "use after free" {
+				t.Errorf("downloaded %q", data)
+			}
+
+			id := challenges[1].ID
+			want := []jeopardy.SubmitStatus{jeopardy.Rejected, jeopardy.Accepted, jeopardy.RateLimited}
+			if tc.api == "ccit" {
+				// CCIT has no way to report a rate limit.
+				want[2] = jeopardy.Rejected
+			}
+			for i, flag := range []string{"flag{nope}", "flag{tcache}", "flag{tcache}"} {
+				res, err := b.Submit(ctx, id, flag)
+				if err != nil {
+					t.Fatalf("Submit failed: %v", err)
+				}
+				if res.Status != want[i] {
+					t.Errorf("submission %d = %s, want %s", i, res.Status, want[i])
+				}
+			}
+
+			solves, err := b.Solves(ctx)
+			if err != nil || len(solves) != 2 {
+				t.Errorf("Solves = %+v, %v", solves, err)
+			}
+
+			tc.settings["token"], tc.settings["team_token"] = "wrong", "wrong"
+			b, _ = jeopardy.Build(tc.backend, tc.settings)
+			if _, err := b.Fetch(ctx); err == nil {
+				t.Errorf("Fetch with a wrong token succeeded")
+			}
+		})
+	}
+}
+
+func TestHandlerNeedsNumericIDs(t *testing.T) {
+	p := &mockserver.Platform{Challenges: []mockserver.Challenge{{ID: "sanity", Name: "sanity"}}}
+	if _, err := mockserver.Handler("ctfd", p); err == nil {
+		t.Error("ctfd accepted a non-numeric id")
+	}
+	if _, err := mockserver.Handler("rctf", p); err != nil {
+		t.Errorf("rctf: %v", err)
+	}
+}
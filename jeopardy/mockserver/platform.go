@@ -0,0 +1,195 @@
+package mockserver
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy"
+)
+
+// Platform is a scripted CTF platform: a fixed set of challenges with their
+// flags and attachments, the solves made so far, and the results queued for
+// the next submissions. It is safe for concurrent use by the servers
+// serving it.
+type Platform struct {
+	Challenges []Challenge
+	// UserID and UserName identify the account the backend logs in as.
+	UserID   int
+	UserName string
+	// Token is the credential the servers require: the CTFd and CCIT API
+	// token, the CTFd session cookie or the rCTF team token. Empty accepts
+	// any.
+	Token string
+	// RateLimit caps flag submissions to RateLimit per RateWindow; further
+	// ones are RateLimited. Zero means no limit.
+	RateLimit  int
+	RateWindow time.Duration
+
+	mu       sync.Mutex
+	solves   []Solve
+	attempts []time.Time
+	script   []jeopardy.SubmitStatus
+	stalled  chan struct{}
+}
+
+// Challenge is a challenge as the platform knows it, flag included.
+type Challenge struct {
+	ID          string
+	Name        string
+	Category    string
+	Description string
+	Points      int
+	Flag        string
+	Files       []File
+}
+
+// File is a challenge attachment.
+type File struct {
+	Name    string
+	Content []byte
+}
+
+// Solve is a challenge solved by the account.
+type Solve struct {
+	ChallengeID string
+	At          time.Time
+}
+
+// Challenge returns the challenge with the given ID, or nil.
+func (p *Platform) Challenge(id string) *Challenge {
+	for i := range p.Challenges {
+		if p.Challenges[i].ID == id {
+			return &p.Challenges[i]
+		}
+	}
+	return nil
+}
+
+// File returns the content of a challenge attachment.
+func (p *Platform) File(challengeID, name string) ([]byte, bool) {
+	c := p.Challenge(challengeID)
+	if c == nil {
+		return nil, false
+	}
+	for _, f := range c.Files {
+		if f.Name == name {
+			return f.Content, true
+		}
+	}
+	return nil, false
+}
+
+// ScriptSubmit queues results for the next submissions, whatever flag they
+// carry. Mock servers report them the way their platform would.
+func (p *Platform) ScriptSubmit(statuses ...jeopardy.SubmitStatus) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.script = append(p.script, statuses...)
+}
+
+// Submit checks a flag: a queued result comes first, then RateLimited past
+// the rate limit, Duplicate for solved challenges, Rejected for wrong flags,
+// and Accepted (recording the solve) for the right one. Unknown challenges
+// get Error.
+func (p *Platform) Submit(challengeID, flag string) jeopardy.SubmitStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.script) > 0 {
+		status := p.script[0]
+		p.script = p.script[1:]
+		return status
+	}
+	now := time.Now()
+	if p.RateLimit > 0 {
+		p.attempts = slices.DeleteFunc(p.attempts, func(t time.Time) bool { return now.Sub(t) >= p.RateWindow })
+		if len(p.attempts) >= p.RateLimit {
+			return jeopardy.RateLimited
+		}
+		p.attempts = append(p.attempts, now)
+	}
+
+	c := p.Challenge(challengeID)
+	switch {
+	case c == nil:
+		return jeopardy.Error
+	case p.solved(challengeID):
+		return jeopardy.Duplicate
+	case flag != c.Flag:
+		return jeopardy.Rejected
+	}
+	p.solves = append(p.solves, Solve{ChallengeID: challengeID, At: now.UTC().Truncate(time.Second)})
+	return jeopardy.Accepted
+}
+
+// MarkSolved records a solve without a submission, e.g. to start from a
+// half-played CTF.
+func (p *Platform) MarkSolved(challengeID string, at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.solved(challengeID) {
+		p.solves = append(p.solves, Solve{ChallengeID: challengeID, At: at.UTC()})
+	}
+}
+
+// authorized reports whether a presented credential is acceptable.
+func (p *Platform) authorized(credential string) bool {
+	return credential != "" && (p.Token == "" || credential == p.Token)
+}
+
+// Solved reports whether the account solved a challenge.
+func (p *Platform) Solved(challengeID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.solved(challengeID)
+}
+
+func (p *Platform) solved(challengeID string) bool {
+	return slices.ContainsFunc(p.solves, func(s Solve) bool { return s.ChallengeID == challengeID })
+}
+
+// Solves returns the account's solves, oldest first.
+func (p *Platform) Solves() []Solve {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.solves)
+}
+
+// Stall makes Wait block until release is called, simulating a platform
+// that stopped answering.
+func (p *Platform) Stall() (release func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ch := make(chan struct{})
+	p.stalled = ch
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			if p.stalled == ch {
+				p.stalled = nil
+			}
+			p.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Wait blocks while the platform is stalled, or until ctx is done. The
+// servers call it before answering each request.
+func (p *Platform) Wait(ctx context.Context) error {
+	p.mu.Lock()
+	ch := p.stalled
+	p.mu.Unlock()
+	if ch == nil {
+		return nil
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,104 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy"
+)
+
+// rctfAuthToken is the auth token the rCTF server hands out on login.
+const rctfAuthToken = "mock-auth-token"
+
+// RCTF serves p through the rCTF API. Platform.Token is the team token;
+// attachments are served without authentication from /uploads, like rCTF's
+// default upload provider.
+func RCTF(p *Platform) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			TeamToken string `json:"teamToken"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if !p.authorized(req.TeamToken) {
+			rctfReply(w, http.StatusUnauthorized, "badTokenVerification", "The token provided is invalid.", nil)
+			return
+		}
+		rctfReply(w, http.StatusOK, "goodLogin", "The login was successful.", map[string]any{"authToken": rctfAuthToken})
+	})
+	mux.HandleFunc("GET /api/v1/challs", rctfAuth(func(w http.ResponseWriter, r *http.Request) {
+		data := make([]map[string]any, 0, len(p.Challenges))
+		for _, c := range p.Challenges {
+			files := make([]map[string]any, 0, len(c.Files))
+			for _, f := range c.Files {
+				files = append(files, map[string]any{
+					"name": f.Name,
+					"url":  "http://" + r.Host + path.Join("/uploads", c.ID, f.Name),
+				})
+			}
+			data = append(data, map[string]any{
+				"id":          c.ID,
+				"name":        c.Name,
+				"description": c.Description,
+				"category":    c.Category,
+				"author":      "mock",
+				"files":       files,
+				"points":      c.Points,
+				"sortWeight":  0,
+			})
+		}
+		rctfReply(w, http.StatusOK, "goodChallenges", "The retrieval of challenges was successful.", data)
+	}))
+	mux.HandleFunc("GET /uploads/{id}/{name}", func(w http.ResponseWriter, r *http.Request) {
+		serveFile(w, p, r.PathValue("id"), r.PathValue("name"))
+	})
+	mux.HandleFunc("POST /api/v1/challs/{id}/submit", rctfAuth(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Flag string `json:"flag"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch p.Submit(r.PathValue("id"), req.Flag) {
+		case jeopardy.Accepted:
+			rctfReply(w, http.StatusOK, "goodFlag", "The flag is correct.", nil)
+		case jeopardy.Duplicate:
+			rctfReply(w, http.StatusConflict, "badAlreadySolvedChallenge", "The flag was already submitted", nil)
+		case jeopardy.RateLimited:
+			rctfReply(w, http.StatusTooManyRequests, "badRateLimit", "You are trying this too fast", map[string]any{"timeLeft": 1000})
+		case jeopardy.Error:
+			rctfReply(w, http.StatusUnauthorized, "badNotStarted", "The CTF has not started yet.", nil)
+		default:
+			rctfReply(w, http.StatusBadRequest, "badFlag", "The flag was incorrect.", nil)
+		}
+	}))
+	mux.HandleFunc("GET /api/v1/users/me", rctfAuth(func(w http.ResponseWriter, r *http.Request) {
+		solves := []map[string]any{}
+		for _, s := range p.Solves() {
+			solve := map[string]any{"id": s.ChallengeID, "createdAt": s.At.UnixMilli()}
+			if c := p.Challenge(s.ChallengeID); c != nil {
+				solve["name"], solve["category"], solve["points"] = c.Name, c.Category, c.Points
+			}
+			solves = append(solves, solve)
+		}
+		rctfReply(w, http.StatusOK, "goodUserSelfData", "The user data was successfully retrieved.", map[string]any{
+			"id":     p.UserName + "-id",
+			"name":   p.UserName,
+			"solves": solves,
+		})
+	}))
+	return serve(p, mux, nil)
+}
+
+func rctfAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+rctfAuthToken {
+			rctfReply(w, http.StatusUnauthorized, "badToken", "The token provided is invalid.", nil)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func rctfReply(w http.ResponseWriter, code int, kind, message string, data any) {
+	writeJSON(w, code, map[string]any{"kind": kind, "message": message, "data": data})
+}
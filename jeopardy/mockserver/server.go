@@ -0,0 +1,37 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// serve wraps a platform's routes: requests wait while p is stalled,
+// and those failing authorized, when set, get a 401.
+func serve(p *Platform, mux *http.ServeMux, authorized func(*http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := p.Wait(r.Context()); err != nil {
+			return
+		}
+		if authorized != nil && !authorized(r) {
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"message": "unauthorized"})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func serveFile(w http.ResponseWriter, p *Platform, challengeID, name string) {
+	content, ok := p.File(challengeID, name)
+	if !ok {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(content)
+}
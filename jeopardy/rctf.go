@@ -47,7 +47,7 @@ func newRCTF(baseURL, teamToken string) (*rctfClient, error) {
 	return &rctfClient{
 		baseURL:   strings.TrimRight(baseURL, "/"),
 		teamToken: teamToken,
-		client:    &http.Client{Timeout: 30 * time.Second},
+		client:    &http.Client{Timeout: 30 * time.Second, Transport: defaultTransport},
 	}, nil
 }
 
@@ -65,12 +65,12 @@ func (c *rctfClient) Fetch(ctx context.Context) ([]Challenge, error) {
 	results := make([]Challenge, 0, len(challenges))
 	for _, chal := range challenges {
 		challenge := Challenge{
-			ID:          chal.ID,
-			Name:        chal.Name,
-			Category:    chal.Category,
-			Description: chal.Description,
-			Points:      chal.Points,
+			ID:       chal.ID,
+			Name:     chal.Name,
+			Category: chal.Category,
+			Points:   chal.Points,
 		}
+		challenge.SetDescription(chal.Description)
 
 		if len(chal.Files) > 0 {
 			challenge.Files = make([]File, 0, len(chal.Files))
@@ -119,12 +119,15 @@ func (c *rctfClient) Submit(ctx context.Context, challengeID, flag string) (*Sub
 	}
 	defer resp.Body.Close()
 	respBody, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("rctf submission failed: %s", strings.TrimSpace(string(respBody)))
-	}
 
+	// rCTF answers wrong flags and rate limits with 4xx statuses, so the
+	// kind in the body decides the result whenever there is one.
 	var parsed rctfSubmitResponse
-	if err := json.Unmarshal(respBody, &parsed); err != nil {
+	err = json.Unmarshal(respBody, &parsed)
+	if resp.StatusCode != http.StatusOK && (err != nil || parsed.Kind == "") {
+		return nil, fmt.Errorf("rctf submission failed: %s", strings.TrimSpace(string(respBody)))
+	}
+	if err != nil {
 		return nil, fmt.Errorf("parse rctf response: %w", err)
 	}
 
@@ -137,17 +140,21 @@ func (c *rctfClient) Solves(ctx context.Context) ([]Solve, error) {
 		return nil, err
 	}
 
-	solves, err := c.fetchUserSolves(ctx, authToken)
+	profile, err := c.fetchUserProfile(ctx, authToken)
 	if err != nil {
 		return nil, err
 	}
 
-	results := make([]Solve, 0, len(solves))
-	for _, solve := range solves {
-		solvedAt := time.Unix(solve.CreatedAt, 0).UTC()
+	// rCTF accounts are teams, so every solve is attributed to the team itself.
+	results := make([]Solve, 0, len(profile.Solves))
+	for _, solve := range profile.Solves {
+		solvedAt := time.UnixMilli(solve.CreatedAt).UTC()
 		results = append(results, Solve{
 			ChallengeID: solve.ID,
 			SolvedAt:    &solvedAt,
+			UserID:      profile.ID,
+			UserName:    profile.Name,
+			Points:      solve.Points,
 		})
 	}
 	return results, nil
@@ -224,7 +231,7 @@ func (c *rctfClient) fetchChallenges(ctx context.Context, authToken string) ([]r
 	return payload.Data, nil
 }
 
-func (c *rctfClient) fetchUserSolves(ctx context.Context, authToken string) ([]rctfUserSolve, error) {
+func (c *rctfClient) fetchUserProfile(ctx context.Context, authToken string) (*rctfUserProfile, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/v1/users/me", nil)
 	if err != nil {
 		return nil, err
@@ -251,7 +258,7 @@ func (c *rctfClient) fetchUserSolves(ctx context.Context, authToken string) ([]r
 		return nil, fmt.Errorf("rctf user profile error: %s", payload.Message)
 	}
 
-	return payload.Data.Solves, nil
+	return &payload.Data, nil
 }
 
 func (c *rctfClient) parseSubmitResponse(parsed rctfSubmitResponse) *SubmitResult {
@@ -315,13 +322,18 @@ type rctfSubmitResponse struct {
 
 type rctfUserSolve struct {
 	ID        string `json:"id"`
-	CreatedAt int64  `json:"createdAt"`
+	Points    int    `json:"points"`
+	CreatedAt int64  `json:"createdAt"` // milliseconds since the epoch
+}
+
+type rctfUserProfile struct {
+	ID     string          `json:"id"`
+	Name   string          `json:"name"`
+	Solves []rctfUserSolve `json:"solves"`
 }
 
 type rctfUserProfileResponse struct {
-	Kind    string `json:"kind"`
-	Message string `json:"message"`
-	Data    struct {
-		Solves []rctfUserSolve `json:"solves"`
-	} `json:"data"`
+	Kind    string          `json:"kind"`
+	Message string          `json:"message"`
+	Data    rctfUserProfile `json:"data"`
 }
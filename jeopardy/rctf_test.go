@@ -0,0 +1,75 @@
+package jeopardy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newRCTFTestServer(t *testing.T, mux *http.ServeMux) *rctfClient {
+	t.Helper()
+	mux.HandleFunc("/api/v1/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"kind": "goodLogin", "data": {"authToken": "auth"}}`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	c, err := newRCTF(srv.URL, "team-token")
+	if err != nil {
+		t.Fatalf("newRCTF failed: %v", err)
+	}
+	return c
+}
+
+// rCTF answers wrong flags and rate limits with 4xx statuses and the kind
+// in the body; only a body without one is a failed request.
+func TestRCTFSubmitErrorStatus(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/challs/{id}/submit", func(w http.ResponseWriter, r *http.Request) {
+		switch r.PathValue("id") {
+		case "wrong":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"kind": "badFlag", "message": "The flag was incorrect.", "data": null}`)
+		case "fast":
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"kind": "badRateLimit", "message": "You are trying this too fast", "data": {"timeLeft": 1000}}`)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprint(w, "<html>bad gateway</html>")
+		}
+	})
+	c := newRCTFTestServer(t, mux)
+	ctx := context.Background()
+
+	for id, want := range map[string]SubmitStatus{"wrong": Rejected, "fast": RateLimited} {
+		result, err := c.Submit(ctx, id, "flag{x}")
+		if err != nil {
+			t.Errorf("Submit(%s) failed: %v", id, err)
+		} else if result.Status != want {
+			t.Errorf("Submit(%s) = %v, want %v", id, result.Status, want)
+		}
+	}
+	if _, err := c.Submit(ctx, "down", "flag{x}"); err == nil {
+		t.Error("Submit succeeded on a 502 without a kind")
+	}
+}
+
+// rCTF's createdAt is a JavaScript timestamp, in milliseconds.
+func TestRCTFSolvesMilliseconds(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/users/me", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"kind": "goodUserSelfData", "data": {"id": "t1", "name": "team", "solves": [{"id": "sanity", "points": 1, "createdAt": 1740823323000}]}}`)
+	})
+	c := newRCTFTestServer(t, mux)
+
+	solves, err := c.Solves(context.Background())
+	if err != nil {
+		t.Fatalf("Solves failed: %v", err)
+	}
+	want := time.Date(2025, 3, 1, 10, 2, 3, 0, time.UTC)
+	if len(solves) != 1 || solves[0].SolvedAt == nil || !solves[0].SolvedAt.Equal(want) {
+		t.Errorf("Solves = %+v, want one at %v", solves, want)
+	}
+}
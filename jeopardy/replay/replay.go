@@ -0,0 +1,234 @@
+// Package replay records platform traffic into fixture files and serves it
+// back, so backends can be tested without a live CTF.
+//
+// Recording uses a jeopardy.Tracer, so fixtures are scrubbed of tokens,
+// cookies and passwords the same way debug traces are:
+//
+//	tracer := &jeopardy.Tracer{MaxBody: replay.MaxBody}
+//	challenges, err := backend.Fetch(jeopardy.WithTracer(ctx, tracer))
+//	err = replay.FromTrace(baseURL, tracer.Entries()).Save("testdata/ctfd.json")
+//
+// Replaying serves the fixture from a local server standing in for the
+// platform:
+//
+//	fixture, err := replay.Load("testdata/ctfd.json")
+//	srv := httptest.NewServer(fixture.Handler())
+//	backend, err := jeopardy.Build("ctfd_token", map[string]string{"base_url": srv.URL, "token": "x"})
+package replay
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy"
+)
+
+// MaxBody is the Tracer.MaxBody to record with: large enough for API
+// responses and small handouts.
+const MaxBody = 8 << 20
+
+// BaseURL stands for the platform's address in recorded bodies and
+// headers, and is replaced by the replay server's address.
+const BaseURL = "{{base_url}}"
+
+// Exchange is one recorded request and its response.
+type Exchange struct {
+	Method string `json:"method"`
+	// Path is the path and query relative to the base URL. Redacted query
+	// values match anything on replay.
+	Path        string            `json:"path"`
+	RequestBody string            `json:"request_body,omitempty"`
+	Status      int               `json:"status"`
+	Header      map[string]string `json:"header,omitempty"`
+	Body        string            `json:"body"`
+	// BodyBase64 holds the body instead of Body when it isn't UTF-8 text,
+	// as with binary handouts, which JSON strings can't carry.
+	BodyBase64 string `json:"body_base64,omitempty"`
+	// Truncated is set when the body was larger than the recording limit.
+	// Truncated exchanges aren't replayed.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Fixture is a recorded session with a platform.
+type Fixture struct {
+	Exchanges []Exchange `json:"exchanges"`
+
+	mu     sync.Mutex
+	served map[int]bool
+}
+
+// recordedHeaders are the response headers worth keeping.
+var recordedHeaders = []string{"Content-Type", "Content-Disposition", "Location", "ETag", "Last-Modified", "Content-Range"}
+
+// FromTrace builds a fixture from the exchanges a Tracer recorded with the
+// platform at baseURL. Exchanges with other hosts are dropped.
+func FromTrace(baseURL string, entries []jeopardy.TraceEntry) *Fixture {
+	baseURL = strings.TrimRight(baseURL, "/")
+	f := &Fixture{}
+	for _, e := range entries {
+		path, ok := strings.CutPrefix(e.URL, baseURL)
+		if !ok || e.Err != "" || (path != "" && !strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "?")) {
+			continue
+		}
+		ex := Exchange{
+			Method:      e.Method,
+			Path:        nonEmpty(path, "/"),
+			RequestBody: e.RequestBody,
+			Status:      e.Status,
+			Truncated:   e.Truncated,
+		}
+		if utf8.ValidString(e.ResponseBody) {
+			ex.Body = strings.ReplaceAll(e.ResponseBody, baseURL, BaseURL)
+		} else {
+			ex.BodyBase64 = base64.StdEncoding.EncodeToString([]byte(e.ResponseBody))
+		}
+		for _, name := range recordedHeaders {
+			if v := e.ResponseHeader.Get(name); v != "" {
+				if ex.Header == nil {
+					ex.Header = make(map[string]string)
+				}
+				ex.Header[name] = strings.ReplaceAll(v, baseURL, BaseURL)
+			}
+		}
+		f.Exchanges = append(f.Exchanges, ex)
+	}
+	return f
+}
+
+// Load reads a fixture file.
+func Load(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse fixture %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// Save writes the fixture as indented JSON, to keep diffs readable.
+func (f *Fixture) Save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Handler serves the fixture. Requests match exchanges by method, path and
+// query; among several matches, unserved ones with the same body come
+// first, then unserved ones in recorded order, then the last match again.
+// Unmatched requests, and those matching a truncated exchange, get a 501
+// naming the request.
+func (f *Fixture) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ex, ok := f.match(r, string(body))
+		if !ok {
+			http.Error(w, fmt.Sprintf("replay: no recorded exchange for %s %s", r.Method, r.URL.RequestURI()), http.StatusNotImplemented)
+			return
+		}
+		if ex.Truncated {
+			http.Error(w, fmt.Sprintf("replay: recorded response to %s %s is truncated", r.Method, r.URL.RequestURI()), http.StatusNotImplemented)
+			return
+		}
+
+		base := "http://" + r.Host
+		for name, v := range ex.Header {
+			w.Header().Set(name, strings.ReplaceAll(v, BaseURL, base))
+		}
+		if ex.BodyBase64 != "" {
+			data, err := base64.StdEncoding.DecodeString(ex.BodyBase64)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("replay: bad body_base64 for %s %s: %v", r.Method, r.URL.RequestURI(), err), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(ex.Status)
+			w.Write(data)
+			return
+		}
+		w.WriteHeader(ex.Status)
+		w.Write([]byte(strings.ReplaceAll(ex.Body, BaseURL, base)))
+	})
+}
+
+func (f *Fixture) match(r *http.Request, body string) (Exchange, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.served == nil {
+		f.served = make(map[int]bool)
+	}
+
+	var candidates []int
+	for i, ex := range f.Exchanges {
+		if ex.Method == r.Method && pathMatches(ex.Path, r.URL) {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return Exchange{}, false
+	}
+
+	pick := -1
+	for _, i := range candidates {
+		if f.served[i] {
+			continue
+		}
+		if f.Exchanges[i].RequestBody == body {
+			pick = i
+			break
+		}
+		if pick < 0 {
+			pick = i
+		}
+	}
+	if pick < 0 {
+		pick = candidates[len(candidates)-1]
+	}
+	f.served[pick] = true
+	return f.Exchanges[pick], true
+}
+
+// pathMatches compares a recorded path with a request URL. Query order
+// doesn't matter, and redacted values match any value.
+func pathMatches(recorded string, u *url.URL) bool {
+	rec, err := url.Parse(recorded)
+	if err != nil || rec.Path != u.Path {
+		return false
+	}
+	want, got := rec.Query(), u.Query()
+	if len(want) != len(got) {
+		return false
+	}
+	for key, values := range want {
+		gotValues := got[key]
+		if len(gotValues) != len(values) {
+			return false
+		}
+		for i, v := range values {
+			if v != jeopardy.Redacted && v != gotValues[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func nonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
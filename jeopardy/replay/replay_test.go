@@ -0,0 +1,154 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy"
+)
+
+// handout is binary, so it doesn't survive as a JSON string.
+const handout = "\x7fELF\x02\x01\x01\x00\xff\xfe\x80\xc3\x28"
+
+func TestRecordAndReplay(t *testing.T) {
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/auth/login":
+			fmt.Fprint(w, `{"kind": "goodLogin", "data": {"authToken": "live-auth-token"}}`)
+		case "/api/v1/challs":
+			if r.Header.Get("Authorization") != "Bearer live-auth-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `{"kind": "badToken"}`)
+				return
+			}
+			fmt.Fprintf(w, `{"kind": "goodChallenges", "data": [{"id": "a", "name": "A", "category": "web", "points": 10,
+				"files": [{"name": "a.txt", "url": "http://%s/uploads/a.txt"}]}]}`, r.Host)
+		case "/uploads/a.txt":
+			fmt.Fprint(w, handout)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer live.Close()
+
+	tracer := &jeopardy.Tracer{MaxBody: MaxBody}
+	ctx := jeopardy.WithTracer(context.Background(), tracer)
+	b, err := jeopardy.Build("rctf", map[string]string{"base_url": live.URL, "team_token": "live-team-token"})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	recorded, err := b.Fetch(ctx)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	r, err := jeopardy.OpenFile(ctx, recorded[0].Files[0])
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	io.Copy(io.Discard, r)
+	r.Close()
+
+	path := filepath.Join(t.TempDir(), "rctf.json")
+	if err := FromTrace(live.URL, tracer.Entries()).Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	for _, secret := range []string{"live-auth-token", "live-team-token", live.URL} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("fixture contains %s", secret)
+		}
+	}
+	if !strings.Contains(string(data), `"body_base64"`) {
+		t.Error("binary handout not recorded as body_base64")
+	}
+	live.Close()
+
+	fixture, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(fixture.Exchanges) != 3 {
+		t.Fatalf("recorded %d exchanges, want 3", len(fixture.Exchanges))
+	}
+	srv := httptest.NewServer(fixture.Handler())
+	defer srv.Close()
+
+	b, err = jeopardy.Build("rctf", map[string]string{"base_url": srv.URL, "team_token": "other"})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	replayed, err := b.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("replayed Fetch failed: %v", err)
+	}
+	if len(replayed) != 1 || replayed[0].Name != "A" || len(replayed[0].Files) != 1 {
+		t.Fatalf("unexpected replayed challenges: %+v", replayed)
+	}
+	info, err := replayed[0].Files[0].DownloadURL(context.Background())
+	if err != nil || !strings.HasPrefix(info.URL, srv.URL+"/") {
+		t.Errorf("replayed file URL = %v (%v), want it under %s", info, err, srv.URL)
+	}
+	r, err = jeopardy.OpenFile(context.Background(), replayed[0].Files[0])
+	if err != nil {
+		t.Fatalf("replayed OpenFile failed: %v", err)
+	}
+	defer r.Close()
+	if got, _ := io.ReadAll(r); string(got) != handout {
+		t.Errorf("replayed handout = %q, want %q", got, handout)
+	}
+}
+
+func TestHandlerMatching(t *testing.T) {
+	f := &Fixture{Exchanges: []Exchange{
+		{Method: "GET", Path: "/files/x?token=%5BREDACTED%5D&v=1", Status: 200, Body: "x"},
+		{Method: "POST", Path: "/submit", RequestBody: `{"flag":"a"}`, Status: 200, Body: "first"},
+		{Method: "POST", Path: "/submit", RequestBody: `{"flag":"b"}`, Status: 400, Body: "second"},
+		{Method: "GET", Path: "/files/big", Status: 200, Body: "partial", Truncated: true},
+	}}
+	srv := httptest.NewServer(f.Handler())
+	defer srv.Close()
+
+	get := func(path string) int {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := get("/files/x?v=1&token=anything"); code != 200 {
+		t.Errorf("redacted query value not matched: %d", code)
+	}
+	if code := get("/files/x?v=2&token=anything"); code != http.StatusNotImplemented {
+		t.Errorf("mismatched query value served: %d", code)
+	}
+	if code := get("/files/big"); code != http.StatusNotImplemented {
+		t.Errorf("truncated exchange served: %d", code)
+	}
+
+	post := func(body string) int {
+		resp, err := http.Post(srv.URL+"/submit", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := post(`{"flag":"b"}`); code != 400 {
+		t.Errorf("body match: got %d, want 400", code)
+	}
+	if code := post(`{"flag":"a"}`); code != 200 {
+		t.Errorf("body match: got %d, want 200", code)
+	}
+	if code := post(`{"flag":"c"}`); code != 400 {
+		t.Errorf("exhausted exchanges should repeat the last match: got %d", code)
+	}
+}
@@ -0,0 +1,113 @@
+package script
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// command is how the script is started.
+type command struct {
+	args []string
+	dir  string
+	env  []string // added to ctf-sync's own environment
+
+	sandbox *sandbox // nil unless the sandbox setting is on
+}
+
+// cmd returns an exec.Cmd for the script, killed when ctx is done.
+func (c *command) cmd(ctx context.Context) (*exec.Cmd, error) {
+	if c.sandbox != nil {
+		return c.sandbox.command(ctx, c)
+	}
+	cmd := exec.CommandContext(ctx, c.args[0], c.args[1:]...)
+	cmd.Dir = c.dir
+	if len(c.env) > 0 {
+		cmd.Env = append(os.Environ(), c.env...)
+	}
+	return cmd, nil
+}
+
+// maxOutput is how much the script may print, 0 for no limit.
+func (c *command) maxOutput() int64 {
+	if c.sandbox == nil {
+		return 0
+	}
+	return c.sandbox.output
+}
+
+// tempDir is where to put files the script writes to: its scratch
+// directory when sandboxed, since it may write nowhere else, or "" for
+// the default.
+func (c *command) tempDir() (string, error) {
+	if c.sandbox == nil {
+		return "", nil
+	}
+	return c.sandbox.scratchDir()
+}
+
+// splitCommand splits a command line into arguments like a POSIX shell:
+// whitespace separates arguments, single quotes keep everything literal,
+// and double quotes and backslashes escape as usual. Nothing is expanded.
+func splitCommand(s string) ([]string, error) {
+	var (
+		args  []string
+		arg   strings.Builder
+		inArg bool
+		quote rune
+	)
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case quote == '"':
+			switch {
+			case r == '"':
+				quote = 0
+			case r == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]):
+				i++
+				if runes[i] != '\n' {
+					arg.WriteRune(runes[i])
+				}
+			default:
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == '\\':
+			if i+1 == len(runes) {
+				return nil, fmt.Errorf("trailing backslash in command")
+			}
+			i++
+			if runes[i] != '\n' {
+				arg.WriteRune(runes[i])
+				inArg = true
+			}
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in command", quote)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,31 @@
+package script
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []string
+	}{
+		{"python3 sync.py", []string{"python3", "sync.py"}},
+		{"  python3   -u\tsync.py ", []string{"python3", "-u", "sync.py"}},
+		{`python3 "/home/me/My CTFs/sync.py"`, []string{"python3", "/home/me/My CTFs/sync.py"}},
+		{`python3 '/tmp/a "b"/sync.py' --name=it\'s`, []string{"python3", `/tmp/a "b"/sync.py`, "--name=it's"}},
+		{`sh -c "echo \"hi\" \$HOME \n"`, []string{"sh", "-c", `echo "hi" $HOME \n`}},
+		{`a\ b "" ''`, []string{"a b", "", ""}},
+		{"", nil},
+	} {
+		got, err := splitCommand(tc.in)
+		if err != nil || !slices.Equal(got, tc.want) {
+			t.Errorf("splitCommand(%q) = %q, %v; want %q", tc.in, got, err, tc.want)
+		}
+	}
+	for _, in := range []string{`python3 "sync.py`, `python3 'sync.py`, `python3 sync.py\`} {
+		if _, err := splitCommand(in); err == nil {
+			t.Errorf("splitCommand(%q) succeeded", in)
+		}
+	}
+}
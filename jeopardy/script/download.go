@@ -0,0 +1,123 @@
+package script
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy"
+)
+
+// servedFile is an attachment listed without a URL, which the script
+// hands over itself through the download action: by writing it to the
+// path it is given, or by streaming it back as base64 chunks.
+type servedFile struct {
+	client      *scriptClient
+	challengeID string
+	id          string
+	name        string
+}
+
+type scriptDownloadRequest struct {
+	scriptRequest
+	ChallengeID string `json:"challenge_id"`
+	File        string `json:"file"`
+	Path        string `json:"path"`
+}
+
+func (f *servedFile) Name() string { return f.name }
+
+// DownloadURL fails: there is nothing to fetch over HTTP. jeopardy.OpenFile
+// and the Downloader use Open instead.
+func (f *servedFile) DownloadURL(ctx context.Context) (*jeopardy.DownloadInfo, error) {
+	return nil, fmt.Errorf("%s is served by the script and has no download url", f.name)
+}
+
+// Open runs the download action into a temporary file and returns it; the
+// file is removed on Close.
+func (f *servedFile) Open(ctx context.Context) (io.ReadCloser, error) {
+	c := f.client
+	if ok, err := c.supports(ctx, "download"); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("%s has no url and the script doesn't support download", f.name)
+	}
+
+	base, err := c.command.tempDir()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(base, "ctf-sync-download-")
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "file")
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	// A persistent script's chunks arrive on another goroutine, possibly
+	// even after a timed out call has returned.
+	var (
+		mu       sync.Mutex
+		streamed bool
+		writeErr error
+	)
+	payload := scriptDownloadRequest{
+		scriptRequest: c.request("download"),
+		ChallengeID:   f.challengeID,
+		File:          f.id,
+		Path:          path,
+	}
+	_, err = c.stream(ctx, payload.Action, payload, func(data []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !streamed {
+			// The script may have opened path itself before deciding to
+			// stream; start the file over.
+			streamed = true
+			if _, writeErr = out.Seek(0, io.SeekStart); writeErr == nil {
+				writeErr = out.Truncate(0)
+			}
+		}
+		if writeErr == nil {
+			_, writeErr = out.Write(data)
+		}
+	})
+	mu.Lock()
+	if closeErr := out.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+	mu.Unlock()
+	if err == nil && writeErr != nil {
+		err = fmt.Errorf("save %s: %w", f.name, writeErr)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	r, err := os.Open(path)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &tempFile{File: r, dir: dir}, nil
+}
+
+// tempFile removes its directory when closed.
+type tempFile struct {
+	*os.File
+	dir string
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	os.RemoveAll(f.dir)
+	return err
+}
@@ -0,0 +1,125 @@
+package script
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Errors reported by scripts, matched with errors.Is against an *Error of
+// the corresponding kind.
+var (
+	ErrAuth        = errors.New("script: not logged in")
+	ErrRateLimited = errors.New("script: rate limited")
+	ErrNotFound    = errors.New("script: not found")
+)
+
+// Error is an error a script reported in a response, as
+//
+//	{"error": {"kind": "auth", "message": "session expired"}}
+//
+// or, from a persistent script, as the JSON-RPC error object. A bare
+// string is accepted as the message.
+type Error struct {
+	// Kind is "auth", "rate_limited", "not_found", or whatever else the
+	// script says; it may be empty.
+	Kind    string
+	Message string
+	// RetryAfter is how long to wait before trying again, for
+	// rate_limited errors that say.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = e.Kind
+	}
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf(" (retry after %s)", e.RetryAfter)
+	}
+	return "script error: " + msg
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrAuth:
+		return e.Kind == "auth"
+	case ErrRateLimited:
+		return e.Kind == "rate_limited"
+	case ErrNotFound:
+		return e.Kind == "not_found"
+	}
+	return false
+}
+
+// parseError decodes the error member of a response. Kind and retry_after
+// may also sit in a JSON-RPC error's data.
+func parseError(raw json.RawMessage) *Error {
+	var obj struct {
+		Kind       string  `json:"kind"`
+		Message    string  `json:"message"`
+		RetryAfter float64 `json:"retry_after"`
+		Data       struct {
+			Kind       string  `json:"kind"`
+			RetryAfter float64 `json:"retry_after"`
+		} `json:"data"`
+	}
+	if json.Unmarshal(raw, &obj) == nil {
+		e := &Error{Kind: obj.Kind, Message: obj.Message}
+		if e.Kind == "" {
+			e.Kind = obj.Data.Kind
+		}
+		retry := obj.RetryAfter
+		if retry == 0 {
+			retry = obj.Data.RetryAfter
+		}
+		e.RetryAfter = time.Duration(retry * float64(time.Second))
+		if e.Kind != "" || e.Message != "" {
+			return e
+		}
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil && s != "" {
+		return &Error{Message: s}
+	}
+	return &Error{Message: string(raw)}
+}
+
+// checkResponse validates a script's response to action: it must be JSON,
+// with nothing printed before it, and its error member, if any, becomes
+// the returned error.
+func checkResponse(action string, output []byte) ([]byte, error) {
+	output = bytes.TrimSpace(output)
+	if len(output) == 0 {
+		return nil, fmt.Errorf("script printed no %s response", action)
+	}
+	if !json.Valid(output) {
+		// Tell stray prints ahead of a good response from a broken one.
+		for i := 1; i < len(output); i++ {
+			if output[i-1] == '\n' && output[i] == '{' && json.Valid(output[i:]) {
+				return nil, fmt.Errorf("script printed text before its %s response, log to stderr instead: %s", action, snippet(output[:i]))
+			}
+		}
+		return nil, fmt.Errorf("script's %s response isn't JSON: %s", action, snippet(output))
+	}
+
+	var resp struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(output, &resp) == nil && len(resp.Error) > 0 && string(resp.Error) != "null" {
+		return nil, parseError(resp.Error)
+	}
+	return output, nil
+}
+
+// snippet quotes the start of some output for an error message.
+func snippet(b []byte) string {
+	b = bytes.TrimSpace(b)
+	if len(b) > 200 {
+		return fmt.Sprintf("%q...", b[:200])
+	}
+	return fmt.Sprintf("%q", b)
+}
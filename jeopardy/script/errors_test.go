@@ -0,0 +1,124 @@
+package script
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckResponse(t *testing.T) {
+	for _, tc := range []struct {
+		output string
+		want   string // error substring, or "" for success
+	}{
+		{`{"status": "accepted"}`, ""},
+		{"\n  {\"status\": \"accepted\"}\n", ""},
+		{"", "printed no submit response"},
+		{"Logging in...\n{\"status\": \"accepted\"}", `text before its submit response, log to stderr instead: "Logging in..."`},
+		{"Traceback (most recent call last):", "isn't JSON"},
+		{`{"error": "unknown challenge"}`, "script error: unknown challenge"},
+		{`{"error": {"kind": "rate_limited", "retry_after": 1.5}}`, "script error: rate_limited (retry after 1.5s)"},
+		{`{"status": "accepted", "error": null}`, ""},
+	} {
+		_, err := checkResponse("submit", []byte(tc.output))
+		if tc.want == "" && err != nil || tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)) {
+			t.Errorf("checkResponse(%q) = %v, want %q", tc.output, err, tc.want)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	e := parseError([]byte(`{"code": -32000, "message": "slow down", "data": {"kind": "rate_limited", "retry_after": 30}}`))
+	if e.Kind != "rate_limited" || e.Message != "slow down" || e.RetryAfter != 30*time.Second {
+		t.Errorf("parseError = %+v", e)
+	}
+	if !errors.Is(e, ErrRateLimited) || errors.Is(e, ErrAuth) {
+		t.Errorf("%v matches the wrong kinds", e)
+	}
+	if e := parseError([]byte(`{"kind": "not_found"}`)); !errors.Is(e, ErrNotFound) || e.Error() != "script error: not_found" {
+		t.Errorf("parseError = %v", e)
+	}
+}
+
+func TestScriptDiagnostics(t *testing.T) {
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+
+	ctx := context.Background()
+	for _, mode := range []string{"oneshot", "persistent"} {
+		t.Run(mode, func(t *testing.T) {
+			logs.Reset()
+			useTestScript(t, mode, "errors")
+			c, err := newScript(map[string]string{"command": os.Args[0], "persistent": "true"})
+			if mode == "oneshot" {
+				c, err = newScript(map[string]string{"command": os.Args[0]})
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			if _, err := c.Submit(ctx, "1", "auth"); !errors.Is(err, ErrAuth) || err.Error() != "script error: session expired" {
+				t.Errorf("auth error = %v", err)
+			}
+
+			if _, err := c.Submit(ctx, "1", "noisy"); err != nil {
+				t.Errorf("noisy submit failed: %v", err)
+			}
+			if mode == "persistent" {
+				// The last line only shows once stderr is closed.
+				c.Close()
+			}
+			for _, want := range []string{`level=WARN msg="script: slow login"`, `level=INFO msg="script: logged in"`} {
+				if !strings.Contains(logs.String(), want) {
+					t.Errorf("logs lack %s:\n%s", want, logs.String())
+				}
+			}
+
+			_, err = c.Submit(ctx, "1", "garbage")
+			if mode == "oneshot" {
+				if err == nil || !strings.Contains(err.Error(), "text before its submit response") {
+					t.Errorf("garbage = %v", err)
+				}
+			} else if err != nil || !strings.Contains(logs.String(), "Logging in...") {
+				t.Errorf("garbage = %v, and not logged:\n%s", err, logs.String())
+			}
+		})
+	}
+}
+
+func TestScriptExitError(t *testing.T) {
+	useTestScript(t, "oneshot", "errors")
+	c, err := newScript(map[string]string{"command": os.Args[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	// An error response explains a non-zero exit.
+	if _, err := c.Submit(ctx, "1", "authexit"); !errors.Is(err, ErrAuth) {
+		t.Errorf("auth error then exit = %v", err)
+	}
+
+	// Otherwise the exit status is the error; stderr was logged already.
+	_, err = c.Submit(ctx, "1", "crash")
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 || strings.Contains(err.Error(), "boom") {
+		t.Errorf("crash = %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package script
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// maxStderrTail is how much of a script's stderr is kept for errors.
+const maxStderrTail = 4096
+
+// stderrLog forwards a script's stderr to slog.Default line by line, with
+// a "script: " prefix, and keeps its tail for error messages. A leading
+// "debug:", "info:", "warning:" or "error:" picks the level; other lines
+// are logged at Info.
+type stderrLog struct {
+	mu      sync.Mutex
+	partial []byte
+	tail    []byte
+}
+
+var stderrLevels = []struct {
+	prefix string
+	level  slog.Level
+}{
+	{"debug:", slog.LevelDebug},
+	{"info:", slog.LevelInfo},
+	{"warning:", slog.LevelWarn},
+	{"warn:", slog.LevelWarn},
+	{"error:", slog.LevelError},
+}
+
+func (w *stderrLog) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.tail = append(w.tail, p...)
+	if len(w.tail) > maxStderrTail {
+		w.tail = w.tail[len(w.tail)-maxStderrTail:]
+	}
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		logLine(string(w.partial[:i]))
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush logs a last line that didn't end in a newline.
+func (w *stderrLog) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.partial) > 0 {
+		logLine(string(w.partial))
+		w.partial = nil
+	}
+}
+
+// String returns the end of what was written.
+func (w *stderrLog) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return string(w.tail)
+}
+
+func logLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	level := slog.LevelInfo
+	for _, l := range stderrLevels {
+		if len(line) >= len(l.prefix) && strings.EqualFold(line[:len(l.prefix)], l.prefix) {
+			level = l.level
+			line = strings.TrimSpace(line[len(l.prefix):])
+			break
+		}
+	}
+	slog.Default().Log(context.Background(), level, "script: "+line)
+}
@@ -0,0 +1,330 @@
+package script
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errExited is wrapped by call errors caused by the script process going
+// away, so that idempotent calls can be retried on a fresh one.
+var errExited = errors.New("script exited")
+
+// persistent keeps a single script process running and multiplexes calls
+// to it as newline-delimited JSON-RPC 2.0 over its stdin and stdout. A
+// process that crashes or stops answering heartbeats is replaced on the
+// next call.
+type persistent struct {
+	command   *command
+	heartbeat time.Duration
+
+	mu   sync.Mutex
+	proc *process
+}
+
+// process is one running instance of the script.
+type process struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr *stderrLog
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]*pendingCall
+	killed  error // why we killed it, if we did
+
+	done chan struct{}
+	err  error // why it exited; set before done is closed
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int64           `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse is a line from the script: a response, or a notification
+// when ID is unset.
+type rpcResponse struct {
+	ID     *int64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  json.RawMessage `json:"error"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// rpcData is the params of a "data" notification, which streams a chunk
+// of a call's output ahead of its response.
+type rpcData struct {
+	ID   int64  `json:"id"`
+	Data []byte `json:"data"`
+}
+
+type pendingCall struct {
+	resp   chan rpcResponse
+	onData func([]byte)
+}
+
+// call sends method to the running process, starting one if needed.
+// onData, if not nil, gets the chunks of data notifications for the call.
+func (p *persistent) call(ctx context.Context, method string, params json.RawMessage, onData func([]byte)) (json.RawMessage, error) {
+	proc, err := p.process()
+	if err != nil {
+		return nil, err
+	}
+	return proc.call(ctx, method, params, onData)
+}
+
+func (p *persistent) process() (*process, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.proc != nil && !p.proc.exited() {
+		return p.proc, nil
+	}
+	proc, err := startProcess(p.command)
+	if err != nil {
+		return nil, err
+	}
+	if p.heartbeat > 0 {
+		go proc.heartbeat(p.heartbeat)
+	}
+	p.proc = proc
+	return proc, nil
+}
+
+// Close stops the script: its stdin is closed so it can exit on its own,
+// and it is killed if it hasn't after a few seconds.
+func (p *persistent) Close() error {
+	p.mu.Lock()
+	proc := p.proc
+	p.proc = nil
+	p.mu.Unlock()
+	if proc == nil {
+		return nil
+	}
+	proc.stdin.Close()
+	select {
+	case <-proc.done:
+	case <-time.After(3 * time.Second):
+		proc.kill(fmt.Errorf("closed"))
+		<-proc.done
+	}
+	return nil
+}
+
+func startProcess(command *command) (*process, error) {
+	cmd, err := command.cmd(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	proc := &process{
+		cmd:     cmd,
+		stdin:   stdin,
+		stderr:  &stderrLog{},
+		pending: make(map[int64]*pendingCall),
+		done:    make(chan struct{}),
+	}
+	cmd.Stderr = proc.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start script: %w", err)
+	}
+	go proc.read(stdout, command.maxOutput())
+	return proc, nil
+}
+
+// read dispatches responses to their callers until stdout is closed, then
+// reaps the process and fails whatever is still pending. A line longer
+// than maxLine, if not 0, kills the process.
+func (pr *process) read(stdout io.Reader, maxLine int64) {
+	r := bufio.NewReader(stdout)
+	for {
+		line, err := readLine(r, maxLine)
+		if errors.Is(err, errOutputLimit) {
+			pr.kill(err)
+			io.Copy(io.Discard, r)
+			break
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var resp rpcResponse
+			if err := json.Unmarshal(line, &resp); err != nil {
+				slog.Warn("script: ignoring stdout line that isn't JSON-RPC, log to stderr instead", "line", string(line))
+			} else {
+				pr.dispatch(resp)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	waitErr := pr.cmd.Wait()
+	pr.stderr.Flush()
+	pr.mu.Lock()
+	reason := pr.killed
+	pr.mu.Unlock()
+	if reason == nil {
+		reason = waitErr
+	}
+	if reason == nil {
+		reason = errors.New("closed its stdout")
+	}
+	if stderr := strings.TrimSpace(pr.stderr.String()); stderr != "" {
+		pr.err = fmt.Errorf("%w: %w: %s", errExited, reason, stderr)
+	} else {
+		pr.err = fmt.Errorf("%w: %w", errExited, reason)
+	}
+	close(pr.done)
+}
+
+// readLine reads a line of at most max bytes, 0 for no limit.
+func readLine(r *bufio.Reader, max int64) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if max > 0 && int64(len(line)) > max {
+			return nil, errOutputLimit
+		}
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+// dispatch hands a response to its caller, or a chunk of data to the call
+// it belongs to.
+func (pr *process) dispatch(resp rpcResponse) {
+	if resp.ID == nil {
+		var data rpcData
+		if resp.Method != "data" || json.Unmarshal(resp.Params, &data) != nil {
+			return
+		}
+		pr.mu.Lock()
+		call := pr.pending[data.ID]
+		pr.mu.Unlock()
+		if call != nil && call.onData != nil {
+			call.onData(data.Data)
+		}
+		return
+	}
+
+	pr.mu.Lock()
+	call := pr.pending[*resp.ID]
+	delete(pr.pending, *resp.ID)
+	pr.mu.Unlock()
+	if call != nil {
+		call.resp <- resp
+	}
+}
+
+func (pr *process) call(ctx context.Context, method string, params json.RawMessage, onData func([]byte)) (json.RawMessage, error) {
+	ch := make(chan rpcResponse, 1)
+	pr.mu.Lock()
+	pr.nextID++
+	id := pr.nextID
+	pr.pending[id] = &pendingCall{resp: ch, onData: onData}
+	pr.mu.Unlock()
+	defer func() {
+		pr.mu.Lock()
+		delete(pr.pending, id)
+		pr.mu.Unlock()
+	}()
+
+	data, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+	if err != nil {
+		return nil, fmt.Errorf("encode script request: %w", err)
+	}
+	pr.writeMu.Lock()
+	_, err = pr.stdin.Write(append(data, '\n'))
+	pr.writeMu.Unlock()
+	if err != nil {
+		// The process is most likely gone; report why once read notices.
+		select {
+		case <-pr.done:
+			return nil, pr.err
+		case <-time.After(time.Second):
+			return nil, fmt.Errorf("%w: %v", errExited, err)
+		}
+	}
+
+	select {
+	case resp := <-ch:
+		if len(resp.Error) > 0 && string(resp.Error) != "null" {
+			return nil, parseError(resp.Error)
+		}
+		return checkResponse(method, resp.Result)
+	case <-pr.done:
+		return nil, pr.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// heartbeat pings the process whenever it is idle and kills it if a ping
+// goes unanswered for interval. Pings aren't sent during calls, so scripts
+// that handle one request at a time aren't killed for being busy.
+func (pr *process) heartbeat(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-pr.done:
+			return
+		case <-t.C:
+		}
+		if pr.busy() {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		_, err := pr.call(ctx, "ping", json.RawMessage(`{"action":"ping"}`), nil)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			pr.kill(fmt.Errorf("no heartbeat reply in %s", interval))
+			return
+		}
+	}
+}
+
+func (pr *process) busy() bool {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	return len(pr.pending) > 0
+}
+
+func (pr *process) exited() bool {
+	select {
+	case <-pr.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (pr *process) kill(reason error) {
+	pr.mu.Lock()
+	if pr.killed == nil {
+		pr.killed = reason
+	}
+	pr.mu.Unlock()
+	pr.cmd.Process.Kill()
+}
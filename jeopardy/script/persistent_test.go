@@ -0,0 +1,144 @@
+package script
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestPersistent(t *testing.T, handler, heartbeat string) *scriptClient {
+	t.Helper()
+	useTestScript(t, "persistent", handler)
+	c, err := newScript(map[string]string{"command": os.Args[0], "persistent": "true", "heartbeat": heartbeat})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func fetchCount(t *testing.T, c *scriptClient) string {
+	t.Helper()
+	challenges, err := c.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	return challenges[0].Name
+}
+
+func TestPersistent(t *testing.T) {
+	c := newTestPersistent(t, "counter", "0")
+	ctx := context.Background()
+
+	if n := fetchCount(t, c); n != "1" {
+		t.Errorf("first fetch handled as call %s", n)
+	}
+	if n := fetchCount(t, c); n != "2" {
+		t.Errorf("second fetch handled as call %s, want the same process", n)
+	}
+
+	// Calls are in flight concurrently: a fetch gets through while a
+	// submit waits for it.
+	waiting := make(chan struct{})
+	var once sync.Once
+	submitted := make(chan error, 1)
+	go func() {
+		_, err := c.persistent.call(ctx, "submit", json.RawMessage(`{"action":"submit","flag":"wait"}`), func([]byte) {
+			once.Do(func() { close(waiting) })
+		})
+		submitted <- err
+	}()
+	select {
+	case <-waiting:
+	case err := <-submitted:
+		t.Fatalf("waiting submit returned early: %v", err)
+	}
+	// Deadlines only keep a broken build from hanging.
+	fetchCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if _, err := c.Fetch(fetchCtx); err != nil {
+		t.Fatalf("fetch during a submit: %v", err)
+	}
+	if err := <-submitted; err != nil {
+		t.Errorf("waiting submit: %v", err)
+	}
+
+	// A crash fails the submit, and the next call gets a new process.
+	_, err := c.Submit(ctx, "1", "crash")
+	if !errors.Is(err, errExited) || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("crashing submit = %v", err)
+	}
+	if n := fetchCount(t, c); n != "1" {
+		t.Errorf("fetch after crash handled as call %s, want a restarted process", n)
+	}
+
+	if _, err := c.persistent.call(ctx, "bogus", nil, nil); err == nil || !strings.Contains(err.Error(), "unknown action bogus") {
+		t.Errorf("unknown method = %v", err)
+	}
+}
+
+// pingLog returns a channel getting a value for every ping the test script
+// answers, which it logs to stderr.
+func pingLog(t *testing.T) <-chan struct{} {
+	pings := make(chan struct{}, 16)
+	prev := slog.Default()
+	slog.SetDefault(slog.New(handlerFunc(func(r slog.Record) {
+		if r.Message == "script: ping" {
+			select {
+			case pings <- struct{}{}:
+			default:
+			}
+		}
+	})))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return pings
+}
+
+type handlerFunc func(slog.Record)
+
+func (f handlerFunc) Enabled(context.Context, slog.Level) bool      { return true }
+func (f handlerFunc) Handle(_ context.Context, r slog.Record) error { f(r); return nil }
+func (f handlerFunc) WithAttrs([]slog.Attr) slog.Handler            { return f }
+func (f handlerFunc) WithGroup(string) slog.Handler                 { return f }
+
+func TestPersistentHeartbeat(t *testing.T) {
+	pings := pingLog(t)
+	// The interval is also how long a ping may take to be answered, so it
+	// is generous enough for a loaded machine.
+	c := newTestPersistent(t, "ping", "500ms")
+	if n := fetchCount(t, c); n != "1" {
+		t.Fatalf("first fetch handled as call %s", n)
+	}
+	for range 2 {
+		select {
+		case <-pings:
+		case <-time.After(30 * time.Second):
+			t.Fatal("script wasn't pinged")
+		}
+	}
+	// Pings are answered, so the process is still the same one.
+	if n := fetchCount(t, c); n != "2" {
+		t.Errorf("process restarted although it answered heartbeats")
+	}
+
+	c = newTestPersistent(t, "noping", "50ms")
+	fetchCount(t, c)
+	proc := c.persistent.proc
+	select {
+	case <-proc.done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("hung script wasn't killed")
+	}
+	if !strings.Contains(proc.err.Error(), "heartbeat") {
+		t.Errorf("exit reason = %v", proc.err)
+	}
+	if n := fetchCount(t, c); n != "1" {
+		t.Errorf("fetch after a missed heartbeat handled as call %s", n)
+	}
+}
@@ -0,0 +1,182 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy"
+)
+
+// sandbox confines the script when the sandbox setting is on. Everywhere,
+// the script only gets an allowlisted environment, a scratch directory as
+// its home, and a cap on its output. On Linux it also runs with the home
+// and config directories hidden, a read-only filesystem except for the
+// scratch directory, optionally
+// without network, with no new privileges, a seccomp filter and resource
+// limits; see sandbox_linux.go.
+type sandbox struct {
+	network bool
+	cpu     time.Duration // 0 for no limit
+	memory  int64         // bytes, 0 for no limit
+	output  int64         // bytes, 0 for no limit
+	env     []string      // names passed through besides sandboxEnv
+	hide    []string      // sandbox_hide, nil for the default
+
+	mu      sync.Mutex
+	scratch string
+}
+
+// sandboxEnv are the variables a sandboxed script keeps from ctf-sync's
+// environment, along with LC_* and whatever sandbox_env lists.
+var sandboxEnv = []string{"PATH", "LANG", "LANGUAGE", "TZ", "TERM", "SSL_CERT_FILE", "SSL_CERT_DIR"}
+
+// sandboxSettings are the settings newSandbox reads.
+var sandboxSettings = []string{"sandbox", "sandbox_network", "sandbox_cpu", "sandbox_memory", "sandbox_output", "sandbox_env", "sandbox_hide"}
+
+// newSandbox returns the sandbox the settings ask for, or nil when the
+// sandbox setting is off.
+func newSandbox(s map[string]string) (*sandbox, error) {
+	if v := s["sandbox"]; v == "" {
+		return nil, nil
+	} else if on, err := strconv.ParseBool(v); err != nil {
+		return nil, fmt.Errorf("invalid sandbox %q: %w", v, err)
+	} else if !on {
+		return nil, nil
+	}
+
+	sb := &sandbox{
+		network: true,
+		cpu:     5 * time.Minute,
+		memory:  2 << 30,
+		output:  64 << 20,
+	}
+	if v := s["sandbox_network"]; v != "" {
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sandbox_network %q: %w", v, err)
+		}
+		sb.network = on
+	}
+	if v := s["sandbox_cpu"]; v != "" {
+		d, err := time.ParseDuration(v)
+		if v == "0" {
+			d, err = 0, nil
+		}
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid sandbox_cpu %q", v)
+		}
+		sb.cpu = d
+	}
+	for _, size := range []struct {
+		key string
+		dst *int64
+	}{{"sandbox_memory", &sb.memory}, {"sandbox_output", &sb.output}} {
+		if v := s[size.key]; v != "" {
+			n, err := jeopardy.ParseSize(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", size.key, err)
+			}
+			*size.dst = n
+		}
+	}
+	for _, name := range strings.Split(s["sandbox_env"], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			sb.env = append(sb.env, name)
+		}
+	}
+	if v, ok := s["sandbox_hide"]; ok {
+		sb.hide = []string{}
+		for _, dir := range strings.Split(v, ",") {
+			if dir = strings.TrimSpace(dir); dir != "" {
+				sb.hide = append(sb.hide, dir)
+			}
+		}
+	}
+	return sb, nil
+}
+
+// environ returns the allowlisted part of ctf-sync's environment, the
+// scratch directory as HOME and TMPDIR, and then extra.
+func (sb *sandbox) environ(scratch string, extra []string) []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, "LC_") || slices.Contains(sandboxEnv, name) || slices.Contains(sb.env, name) {
+			env = append(env, kv)
+		}
+	}
+	env = append(env, "HOME="+scratch, "TMPDIR="+scratch)
+	return append(env, extra...)
+}
+
+// scratchDir returns the directory the script may write to, creating it
+// on first use. It is kept across calls and removed by close.
+func (sb *sandbox) scratchDir() (string, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if sb.scratch == "" {
+		dir, err := os.MkdirTemp("", "ctf-sync-sandbox-")
+		if err != nil {
+			return "", fmt.Errorf("create sandbox scratch directory: %w", err)
+		}
+		sb.scratch = dir
+	}
+	return sb.scratch, nil
+}
+
+func (sb *sandbox) close() error {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if sb.scratch == "" {
+		return nil
+	}
+	err := os.RemoveAll(sb.scratch)
+	sb.scratch = ""
+	return err
+}
+
+// limitedBuffer collects output up to max bytes (0 for no limit) and
+// fails writes past it, calling onExceed the first time.
+type limitedBuffer struct {
+	max      int64
+	onExceed func()
+	buf      []byte
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.max > 0 && int64(len(b.buf)+len(p)) > b.max {
+		if !b.exceeded && b.onExceed != nil {
+			b.onExceed()
+		}
+		b.exceeded = true
+		return 0, errOutputLimit
+	}
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
+
+// limitedReader fails reads past max bytes (0 for no limit).
+type limitedReader struct {
+	r    io.Reader
+	max  int64
+	read int64
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+	if r.max > 0 && r.read > r.max {
+		return n, errOutputLimit
+	}
+	return n, err
+}
+
+var errOutputLimit = errors.New("script output exceeds the sandbox_output limit")
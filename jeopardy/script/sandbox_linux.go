@@ -0,0 +1,497 @@
+package script
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// A sandboxed script is started by re-executing the running binary, which
+// imports this package, in new user, mount, PID, IPC and UTS namespaces
+// (and a network one without network). Its init then hides the home and
+// config directories behind an empty tmpfs, makes the filesystem
+// read-only except for the scratch directory, sets resource
+// limits, no_new_privs and a seccomp filter, and execs the script. Where
+// namespaces can't be created, the same happens without them, and without
+// the filesystem and network isolation they bring.
+
+// sandboxInitEnv carries the sandboxConfig to the re-executed binary.
+const sandboxInitEnv = "CTF_SYNC_SANDBOX_INIT"
+
+// sandboxConfig is what the re-executed binary needs to start the script.
+type sandboxConfig struct {
+	Probe    bool     `json:"probe,omitempty"` // just check that namespaces work
+	Args     []string `json:"args"`
+	Scratch  string   `json:"scratch"`
+	Hide     []string `json:"hide,omitempty"` // covered with an empty tmpfs
+	Keep     []string `json:"keep,omitempty"` // left visible inside Hide
+	Isolated bool     `json:"isolated"`       // running in new namespaces
+	CPU      uint64   `json:"cpu"`            // seconds
+	Memory   uint64   `json:"memory"`
+	FileSize uint64   `json:"file_size"`
+}
+
+// sandboxNamespaces are the namespaces every isolated script gets;
+// CLONE_NEWNET is added without network.
+const sandboxNamespaces = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+
+func init() {
+	if data, ok := os.LookupEnv(sandboxInitEnv); ok {
+		sandboxInit(data)
+	}
+}
+
+var (
+	namespacesOnce sync.Once
+	namespacesErr  error
+	degradedOnce   sync.Once
+)
+
+// namespacesAvailable checks, once, that a process can be started in the
+// sandbox's namespaces; unprivileged user namespaces are often disabled.
+func namespacesAvailable() error {
+	namespacesOnce.Do(func() {
+		cmd := exec.Command("/proc/self/exe")
+		cmd.Args = []string{"ctf-sync-sandbox"}
+		cmd.Env = []string{sandboxInitEnv + `={"probe":true}`}
+		cmd.SysProcAttr = namespaceAttr(false)
+		namespacesErr = cmd.Run()
+	})
+	return namespacesErr
+}
+
+func namespaceAttr(network bool) *syscall.SysProcAttr {
+	flags := uintptr(sandboxNamespaces)
+	if !network {
+		flags |= syscall.CLONE_NEWNET
+	}
+	// Only our own IDs can be mapped without privileges, and setgroups
+	// stays denied, as an unprivileged gid map requires.
+	return &syscall.SysProcAttr{
+		Cloneflags:  flags,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}},
+	}
+}
+
+func (sb *sandbox) command(ctx context.Context, c *command) (*exec.Cmd, error) {
+	scratch, err := sb.scratchDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg := sandboxConfig{
+		Args:     c.args,
+		Scratch:  scratch,
+		Hide:     resolveDirs(sb.hidden()),
+		CPU:      uint64((sb.cpu + 999_999_999) / 1_000_000_000),
+		Memory:   uint64(sb.memory),
+		FileSize: uint64(sb.output),
+	}
+	// The script needs its scratch directory, its working directory and
+	// its executable, wherever they are.
+	workdir := c.dir
+	if workdir == "" {
+		workdir, _ = os.Getwd()
+	}
+	keep := []string{scratch, workdir}
+	if path := executablePath(c.args[0], workdir); path != "" {
+		keep = append(keep, filepath.Dir(path))
+	}
+	cfg.Keep = resolveDirs(keep)
+
+	cmd := exec.CommandContext(ctx, "/proc/self/exe")
+	cmd.Args = []string{"ctf-sync-sandbox"}
+	cmd.Dir = c.dir
+	if err := namespacesAvailable(); err != nil {
+		degradedOnce.Do(func() {
+			slog.Warn("script sandbox: can't create namespaces, so the script can write outside its scratch directory and reach the network; only the environment, resource limits and seccomp filter apply", "err", err)
+		})
+	} else {
+		cfg.Isolated = true
+		cmd.SysProcAttr = namespaceAttr(sb.network)
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Env = append(sb.environ(scratch, c.env), sandboxInitEnv+"="+string(data))
+	return cmd, nil
+}
+
+// hidden returns the directories to hide from the script: sandbox_hide,
+// or by default the user's home and config directories.
+func (sb *sandbox) hidden() []string {
+	if sb.hide != nil {
+		return sb.hide
+	}
+	var dirs []string
+	for _, dir := range []func() (string, error){os.UserHomeDir, os.UserConfigDir} {
+		if d, err := dir(); err == nil {
+			dirs = append(dirs, d)
+		}
+	}
+	return dirs
+}
+
+// executablePath finds the script's executable as the sandbox will, or
+// returns "".
+func executablePath(name, workdir string) string {
+	if !strings.Contains(name, "/") {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			return ""
+		}
+		name = path
+	}
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(workdir, name)
+	}
+	return name
+}
+
+// resolveDirs makes dirs absolute and resolves their symlinks, dropping
+// the ones that don't exist: mounts go on the real paths.
+func resolveDirs(dirs []string) []string {
+	var out []string
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			out = append(out, resolved)
+		}
+	}
+	return out
+}
+
+// sandboxInit runs in the re-executed binary, before main: it confines
+// itself as cfg says and execs the script. It never returns.
+func sandboxInit(data string) {
+	// prctl settings are per thread, and must be on the one that execs.
+	runtime.LockOSThread()
+	var cfg sandboxConfig
+	if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: bad config: %v\n", err)
+		os.Exit(127)
+	}
+	if cfg.Probe {
+		os.Exit(0)
+	}
+	os.Unsetenv(sandboxInitEnv)
+	warn := func(what string, err error) {
+		fmt.Fprintf(os.Stderr, "warning: sandbox: %s: %v\n", what, err)
+	}
+
+	if cfg.Isolated {
+		if err := isolateFilesystem(cfg); err != nil {
+			warn("filesystem stays writable", err)
+		}
+	}
+	for _, l := range []struct {
+		name     string
+		resource int
+		value    uint64
+		slack    uint64 // hard limit above the soft one
+	}{
+		// Past the soft CPU limit the script gets SIGXCPU, and a second
+		// later SIGKILL.
+		{"cpu limit", syscall.RLIMIT_CPU, cfg.CPU, 1},
+		{"memory limit", syscall.RLIMIT_DATA, cfg.Memory, 0},
+		{"file size limit", syscall.RLIMIT_FSIZE, cfg.FileSize, 0},
+	} {
+		if l.value == 0 {
+			continue
+		}
+		if err := syscall.Setrlimit(l.resource, &syscall.Rlimit{Cur: l.value, Max: l.value + l.slack}); err != nil {
+			warn(l.name, err)
+		}
+	}
+	if err := prctl(prSetNoNewPrivs, 1); err != nil {
+		warn("no_new_privs", err)
+	}
+	if cfg.Isolated {
+		// The script runs as our own uid, which may be root in the
+		// namespace; don't let exec hand it the namespace's capabilities.
+		if err := prctl(prSetSecurebits, secbitNoroot|secbitNorootLocked); err != nil {
+			warn("securebits", err)
+		}
+	}
+	if err := installSeccomp(); err != nil {
+		warn("seccomp", err)
+	}
+
+	path, err := exec.LookPath(cfg.Args[0])
+	if errors.Is(err, exec.ErrDot) {
+		err = nil
+	}
+	if err == nil {
+		err = syscall.Exec(path, cfg.Args, os.Environ())
+	}
+	fmt.Fprintf(os.Stderr, "sandbox: run %s: %v\n", cfg.Args[0], err)
+	os.Exit(127)
+}
+
+// isolateFilesystem hides cfg.Hide, makes every mount read-only but the
+// scratch directory, and mounts a /proc for the new PID namespace.
+func isolateFilesystem(cfg sandboxConfig) error {
+	scratch := cfg.Scratch
+	// Keep what follows from propagating back to the host.
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+	// Best effort: containers often lock /proc, and the host's still works.
+	if err := syscall.Mount("proc", "/proc", "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: sandbox: mount /proc: %v\n", err)
+	}
+	if err := syscall.Mount(scratch, scratch, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind scratch directory: %w", err)
+	}
+	var hidden []string
+	for _, dir := range cfg.Hide {
+		// The config directory is usually inside the home directory, and
+		// a directory holding the working directory can't be hidden.
+		if slices.ContainsFunc(hidden, func(h string) bool { return within(dir, h) }) ||
+			slices.ContainsFunc(cfg.Keep, func(k string) bool { return within(dir, k) }) {
+			continue
+		}
+		if err := hideDir(dir, cfg.Keep); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: sandbox: %s stays visible: %v\n", dir, err)
+			continue
+		}
+		hidden = append(hidden, dir)
+	}
+	if err := setReadOnly("/", true, true); err != nil {
+		return err
+	}
+	if err := setReadOnly(scratch, false, false); err != nil {
+		return fmt.Errorf("make scratch directory writable: %w", err)
+	}
+	return nil
+}
+
+// hideDir mounts an empty tmpfs over dir, then binds the directories of
+// keep that are inside it back in place.
+func hideDir(dir string, keep []string) error {
+	var inside []*os.File
+	defer func() {
+		for _, f := range inside {
+			f.Close()
+		}
+	}()
+	for _, k := range keep {
+		if within(k, dir) {
+			// The open directory stays reachable through /proc/self/fd
+			// once the tmpfs covers its path.
+			f, err := os.Open(k)
+			if err != nil {
+				return err
+			}
+			inside = append(inside, f)
+		}
+	}
+
+	if err := syscall.Mount("tmpfs", dir, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=755"); err != nil {
+		return fmt.Errorf("mount tmpfs: %w", err)
+	}
+	for _, f := range inside {
+		err := os.MkdirAll(f.Name(), 0755)
+		if err == nil {
+			err = syscall.Mount(fmt.Sprintf("/proc/self/fd/%d", f.Fd()), f.Name(), "", syscall.MS_BIND|syscall.MS_REC, "")
+		}
+		if err != nil {
+			syscall.Unmount(dir, syscall.MNT_DETACH)
+			return fmt.Errorf("keep %s: %w", f.Name(), err)
+		}
+	}
+	return nil
+}
+
+// within reports whether path is dir or below it.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+const (
+	sysMountSetattr = 442
+	atRecursive     = 0x8000
+	mountAttrRdonly = 0x1
+
+	prSetSecurebits    = 28
+	prSetNoNewPrivs    = 38
+	secbitNoroot       = 1 << 0
+	secbitNorootLocked = 1 << 1
+)
+
+// setReadOnly changes whether the mount at path, and with recursive every
+// mount below it, is read-only. It uses mount_setattr, or remounts one
+// mount at a time on kernels before 5.12.
+func setReadOnly(path string, readOnly, recursive bool) error {
+	attr := struct{ set, clr, propagation, userns uint64 }{}
+	if readOnly {
+		attr.set = mountAttrRdonly
+	} else {
+		attr.clr = mountAttrRdonly
+	}
+	var flags uintptr
+	if recursive {
+		flags = atRecursive
+	}
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	atFdcwd := -100
+	_, _, errno := syscall.Syscall6(sysMountSetattr, uintptr(atFdcwd), uintptr(unsafe.Pointer(p)), flags, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno == 0 {
+		return nil
+	}
+	if errno != syscall.ENOSYS {
+		return fmt.Errorf("mount_setattr %s: %w", path, errno)
+	}
+
+	paths := []string{path}
+	if recursive {
+		if paths, err = mountsUnder(path); err != nil {
+			return err
+		}
+	}
+	for i, mp := range paths {
+		if err := remount(mp, readOnly); err != nil && i == 0 {
+			return err
+		}
+	}
+	return nil
+}
+
+// remount changes whether one mount is read-only, keeping the flags that
+// can't be changed from a user namespace.
+func remount(path string, readOnly bool) error {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return err
+	}
+	flags := uintptr(syscall.MS_REMOUNT | syscall.MS_BIND)
+	for _, f := range []struct{ st, ms uintptr }{
+		{0x2, syscall.MS_NOSUID},
+		{0x4, syscall.MS_NODEV},
+		{0x8, syscall.MS_NOEXEC},
+		{0x400, syscall.MS_NOATIME},
+		{0x800, syscall.MS_NODIRATIME},
+		{0x1000, syscall.MS_RELATIME},
+	} {
+		if uintptr(st.Flags)&f.st != 0 {
+			flags |= f.ms
+		}
+	}
+	if readOnly {
+		flags |= syscall.MS_RDONLY
+	}
+	if err := syscall.Mount("", path, "", flags, ""); err != nil {
+		return fmt.Errorf("remount %s: %w", path, err)
+	}
+	return nil
+}
+
+// mountsUnder lists the mount points at or below root, root first.
+func mountsUnder(root string) ([]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	paths := []string{root}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		mp := unescapeMountPath(fields[4])
+		if mp != root && (root == "/" || strings.HasPrefix(mp, root+"/")) {
+			paths = append(paths, mp)
+		}
+	}
+	return paths, s.Err()
+}
+
+// unescapeMountPath undoes mountinfo's octal escapes (\040 for a space).
+func unescapeMountPath(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			var c byte
+			if _, err := fmt.Sscanf(s[i+1:i+4], "%03o", &c); err == nil {
+				b.WriteByte(c)
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func prctl(option, arg uintptr) error {
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, option, arg, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+const (
+	prSetSeccomp      = 22
+	seccompModeFilter = 2
+
+	bpfLdAbs = 0x20 // BPF_LD | BPF_W | BPF_ABS
+	bpfJeq   = 0x15 // BPF_JMP | BPF_JEQ | BPF_K
+	bpfJge   = 0x35 // BPF_JMP | BPF_JGE | BPF_K
+	bpfRet   = 0x06 // BPF_RET | BPF_K
+
+	seccompRetKillProcess = 0x80000000
+	seccompRetErrno       = 0x00050000
+	seccompRetAllow       = 0x7fff0000
+)
+
+// installSeccomp denies deniedSyscalls with EPERM and kills the script if
+// it makes syscalls for another architecture.
+func installSeccomp() error {
+	if auditArch == 0 {
+		return fmt.Errorf("no filter for %s", runtime.GOARCH)
+	}
+	deny := syscall.SockFilter{Code: bpfRet, K: seccompRetErrno | uint32(syscall.EPERM)}
+	filter := []syscall.SockFilter{
+		{Code: bpfLdAbs, K: 4}, // seccomp_data.arch
+		{Code: bpfJeq, Jt: 1, K: auditArch},
+		{Code: bpfRet, K: seccompRetKillProcess},
+		{Code: bpfLdAbs, K: 0}, // seccomp_data.nr
+		// x32 syscalls share x86-64's arch but have this bit set.
+		{Code: bpfJge, Jf: 1, K: 0x40000000},
+		deny,
+	}
+	for _, nr := range deniedSyscalls {
+		filter = append(filter, syscall.SockFilter{Code: bpfJeq, Jf: 1, K: nr}, deny)
+	}
+	filter = append(filter, syscall.SockFilter{Code: bpfRet, K: seccompRetAllow})
+
+	prog := syscall.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetSeccomp, seccompModeFilter, uintptr(unsafe.Pointer(&prog))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package script
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rw-r-r-0644/ctf-sync/jeopardy"
+)
+
+// newSandboxedScript returns a client running the test binary sandboxed,
+// in mode, skipping the test where namespaces are unavailable. It runs
+// testSandboxScript unless settings set env.CTF_SYNC_TEST_HANDLER. The
+// environment CTF_SYNC_TEST_* needs is passed on explicitly, since the
+// sandbox drops the rest.
+func newSandboxedScript(t *testing.T, mode string, settings map[string]string) *scriptClient {
+	t.Helper()
+	if err := namespacesAvailable(); err != nil {
+		t.Skipf("can't create namespaces: %v", err)
+	}
+	s := map[string]string{
+		"command":                   os.Args[0],
+		"sandbox":                   "true",
+		"env.CTF_SYNC_TEST_SCRIPT":  mode,
+		"env.CTF_SYNC_TEST_HANDLER": "sandbox",
+		"env.GORACE":                os.Getenv("GORACE"),
+	}
+	if mode == "persistent" {
+		s["persistent"] = "true"
+	}
+	for k, v := range settings {
+		s[k] = v
+	}
+	c, err := newScript(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func sandboxReport(t *testing.T, c *scriptClient, params map[string]any) map[string]any {
+	t.Helper()
+	req := map[string]any{"action": "sandbox"}
+	for k, v := range params {
+		req[k] = v
+	}
+	out, err := c.run(context.Background(), "sandbox", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report map[string]any
+	if err := json.Unmarshal(out, &report); err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestSandboxIsolation(t *testing.T) {
+	t.Setenv("CTF_SYNC_TEST_SECRET", "hunter2")
+	outside := t.TempDir()
+	for _, mode := range []string{"oneshot", "persistent"} {
+		t.Run(mode, func(t *testing.T) {
+			c := newSandboxedScript(t, mode, map[string]string{"sandbox_network": "false"})
+			report := sandboxReport(t, c, map[string]any{"outside": outside})
+
+			if report["outside"] == "" {
+				t.Error("script wrote outside its scratch directory")
+			}
+			if report["scratch"] != "" {
+				t.Errorf("script can't write its scratch directory: %v", report["scratch"])
+			}
+			scratch, _ := c.command.sandbox.scratchDir()
+			if report["home"] != scratch {
+				t.Errorf("HOME = %v, want %s", report["home"], scratch)
+			}
+			if report["secret"] != "" {
+				t.Errorf("script sees CTF_SYNC_TEST_SECRET=%v", report["secret"])
+			}
+			if ifaces, _ := json.Marshal(report["interfaces"]); string(ifaces) != `["lo"]` {
+				t.Errorf("interfaces = %s, want only lo", ifaces)
+			}
+			if report["NoNewPrivs"] != "1" || report["Seccomp"] != "2" {
+				t.Errorf("NoNewPrivs = %v, Seccomp = %v; want 1 and 2", report["NoNewPrivs"], report["Seccomp"])
+			}
+
+			c.Close()
+			if _, err := os.Stat(scratch); !os.IsNotExist(err) {
+				t.Errorf("scratch directory left behind: %v", err)
+			}
+		})
+	}
+}
+
+func TestSandboxHidesHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	token := filepath.Join(home, ".config", "ctf-sync", "ctf-sync.json")
+	workdir := filepath.Join(home, "ctf")
+	for _, dir := range []string{filepath.Dir(token), workdir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for path, content := range map[string]string{token: `{"config": {"token": "hunter2"}}`, filepath.Join(workdir, "notes.txt"): "kept"} {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := newSandboxedScript(t, "oneshot", map[string]string{"workdir": workdir})
+	report := sandboxReport(t, c, map[string]any{"read": []string{token, filepath.Join(workdir, "notes.txt")}})
+	read, _ := report["read"].([]any)
+	if len(read) != 2 || read[0] == "" || read[1] != "" {
+		t.Errorf("reading the config and the working directory = %v, want only the second to work", report["read"])
+	}
+	if report["scratch"] != "" {
+		t.Errorf("script can't write its scratch directory: %v", report["scratch"])
+	}
+
+	c = newSandboxedScript(t, "oneshot", map[string]string{"workdir": workdir, "sandbox_hide": ""})
+	report = sandboxReport(t, c, map[string]any{"read": []string{token}})
+	if read, _ := report["read"].([]any); len(read) != 1 || read[0] != "" {
+		t.Errorf("with sandbox_hide empty, reading the config = %v", report["read"])
+	}
+}
+
+func TestSandboxDownload(t *testing.T) {
+	c := newSandboxedScript(t, "oneshot", map[string]string{
+		"env.CTF_SYNC_TEST_HANDLER": "v2",
+		"env.CTF_SYNC_TEST_ACTIONS": "fetch,download",
+	})
+	challs, err := c.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := jeopardy.OpenFile(context.Background(), challs[0].Files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	scratch, _ := c.command.sandbox.scratchDir()
+	if f, ok := rc.(*tempFile); !ok || !strings.HasPrefix(f.Name(), scratch+string(filepath.Separator)) {
+		t.Errorf("download not written to the scratch directory")
+	}
+}
+
+func TestSandboxLimits(t *testing.T) {
+	t.Run("cpu", func(t *testing.T) {
+		c := newSandboxedScript(t, "oneshot", map[string]string{"sandbox_cpu": "1s"})
+		if _, err := c.run(context.Background(), "sandbox", map[string]any{"action": "sandbox", "op": "spin"}); err == nil {
+			t.Error("spinning script wasn't stopped")
+		}
+	})
+	t.Run("memory", func(t *testing.T) {
+		c := newSandboxedScript(t, "oneshot", map[string]string{"sandbox_memory": "256M", "env.GOTRACEBACK": "none"})
+		if _, err := c.run(context.Background(), "sandbox", map[string]any{"action": "sandbox", "op": "alloc"}); err == nil {
+			t.Error("script allocated past its memory limit")
+		}
+	})
+	for _, mode := range []string{"oneshot", "persistent"} {
+		t.Run("output/"+mode, func(t *testing.T) {
+			c := newSandboxedScript(t, mode, map[string]string{"sandbox_output": "64K"})
+			_, err := c.run(context.Background(), "sandbox", map[string]any{"action": "sandbox", "op": "flood"})
+			if !errors.Is(err, errOutputLimit) {
+				t.Errorf("err = %v, want the output limit", err)
+			}
+		})
+	}
+}
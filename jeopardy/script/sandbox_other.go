@@ -0,0 +1,28 @@
+//go:build !linux
+
+package script
+
+import (
+	"context"
+	"log/slog"
+	"os/exec"
+	"sync"
+)
+
+var unsupportedOnce sync.Once
+
+// command runs the script with the sandbox's environment and scratch
+// directory only: isolation and resource limits need Linux.
+func (sb *sandbox) command(ctx context.Context, c *command) (*exec.Cmd, error) {
+	unsupportedOnce.Do(func() {
+		slog.Warn("script sandbox: only the environment and output limit apply outside Linux; the script can write anywhere and reach the network")
+	})
+	scratch, err := sb.scratchDir()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, c.args[0], c.args[1:]...)
+	cmd.Dir = c.dir
+	cmd.Env = sb.environ(scratch, c.env)
+	return cmd, nil
+}
@@ -0,0 +1,125 @@
+package script
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSandboxScript answers sandbox with testSandboxReport.
+func testSandboxScript(action string, req map[string]any, _ func([]byte)) (any, error) {
+	if action != "sandbox" {
+		return nil, testUnknownAction(action)
+	}
+	return testSandboxReport(req), nil
+}
+
+// testSandboxReport tells what the script could do, for the sandbox
+// action: req's "outside" is a directory to try writing to, "read" lists
+// files to try reading, and "op" may ask it to exceed a limit instead.
+func testSandboxReport(req map[string]any) map[string]any {
+	switch req["op"] {
+	case "spin":
+		for {
+		}
+	case "flood":
+		fmt.Print(strings.Repeat("x", 1<<20))
+	case "alloc":
+		b := make([]byte, 1<<30)
+		for i := range b {
+			b[i] = 1
+		}
+		return map[string]any{"allocated": len(b)}
+	}
+
+	report := map[string]any{
+		"secret": os.Getenv("CTF_SYNC_TEST_SECRET"),
+		"home":   os.Getenv("HOME"),
+	}
+	if dir, ok := req["outside"].(string); ok {
+		report["outside"] = errString(os.WriteFile(filepath.Join(dir, "escaped"), nil, 0644))
+	}
+	if paths, ok := req["read"].([]any); ok {
+		var errs []string
+		for _, p := range paths {
+			_, err := os.ReadFile(p.(string))
+			errs = append(errs, errString(err))
+		}
+		report["read"] = errs
+	}
+	report["scratch"] = errString(os.WriteFile(filepath.Join(os.Getenv("HOME"), "note"), nil, 0644))
+	var names []string
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		names = append(names, iface.Name)
+	}
+	report["interfaces"] = names
+	status, _ := os.ReadFile("/proc/self/status")
+	for _, line := range strings.Split(string(status), "\n") {
+		if k, v, ok := strings.Cut(line, ":"); ok && (k == "NoNewPrivs" || k == "Seccomp") {
+			report[k] = strings.TrimSpace(v)
+		}
+	}
+	return report
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func TestNewSandbox(t *testing.T) {
+	if sb, err := newSandbox(map[string]string{"sandbox": "false", "sandbox_cpu": "1s"}); sb != nil || err != nil {
+		t.Errorf("sandbox off = %v, %v", sb, err)
+	}
+	sb, err := newSandbox(map[string]string{
+		"sandbox":         "true",
+		"sandbox_network": "false",
+		"sandbox_cpu":     "0",
+		"sandbox_memory":  "256M",
+		"sandbox_env":     "API_TOKEN, PROXY",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sb.network || sb.cpu != 0 || sb.memory != 256<<20 || sb.output != 64<<20 || strings.Join(sb.env, ",") != "API_TOKEN,PROXY" {
+		t.Errorf("sandbox = %+v", sb)
+	}
+	if sb, _ := newSandbox(map[string]string{"sandbox": "1"}); sb.cpu != 5*time.Minute || !sb.network {
+		t.Errorf("defaults = %+v", sb)
+	}
+	for _, s := range []map[string]string{
+		{"sandbox": "maybe"},
+		{"sandbox": "true", "sandbox_network": "sometimes"},
+		{"sandbox": "true", "sandbox_cpu": "-1s"},
+		{"sandbox": "true", "sandbox_output": "big"},
+	} {
+		if _, err := newSandbox(s); err == nil {
+			t.Errorf("newSandbox(%v) succeeded", s)
+		}
+	}
+}
+
+func TestSandboxEnvironment(t *testing.T) {
+	t.Setenv("CTF_SYNC_TEST_SECRET", "hunter2")
+	t.Setenv("PROXY_URL", "http://proxy")
+	sb, err := newSandbox(map[string]string{"sandbox": "true", "sandbox_env": "PROXY_URL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := strings.Join(sb.environ("/scratch", []string{"EXTRA=1"}), "\n")
+	for _, want := range []string{"PROXY_URL=http://proxy", "HOME=/scratch", "TMPDIR=/scratch", "EXTRA=1"} {
+		if !strings.Contains(env, want) {
+			t.Errorf("environment lacks %s:\n%s", want, env)
+		}
+	}
+	if strings.Contains(env, "hunter2") {
+		t.Errorf("environment leaks CTF_SYNC_TEST_SECRET:\n%s", env)
+	}
+}
@@ -4,9 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rw-r-r-0644/ctf-sync/jeopardy"
@@ -18,32 +24,185 @@ func init() {
 		Name: "Custom Script",
 		Settings: []jeopardy.SettingDef{
 			{ID: "command", Name: "Command", Required: true},
+			{ID: "persistent", Name: "Keep the script running and talk JSON-RPC to it (true/false)"},
+			{ID: "heartbeat", Name: "Ping interval for a persistent script, 0 to disable (default 30s)"},
+			{ID: "timeout", Name: "How long a call may take (default 2m)"},
+			{ID: "workdir", Name: "Directory to run the script in"},
+			{ID: "env.<NAME>", Name: "Environment variable to set for the script"},
+			{ID: "sandbox", Name: "Run the script confined, Linux only for full isolation (true/false)"},
+			{ID: "sandbox_network", Name: "Let a sandboxed script use the network (default true)"},
+			{ID: "sandbox_cpu", Name: "CPU time limit for a sandboxed script, 0 for none (default 5m)"},
+			{ID: "sandbox_memory", Name: "Memory limit for a sandboxed script, 0 for none (default 2G)"},
+			{ID: "sandbox_output", Name: "Output and file size limit for a sandboxed script, 0 for none (default 64M)"},
+			{ID: "sandbox_env", Name: "Comma-separated environment variables a sandboxed script keeps"},
 		},
 		Build: func(s map[string]string) (jeopardy.Backend, error) {
-			return newScript(s["command"])
+			return newScript(s)
 		},
 	})
 }
 
+// protocolVersion is the newest script protocol this package speaks.
+// Version 1 scripts predate the hello action.
+const protocolVersion = 2
+
+// v1Actions are the actions every script is assumed to support.
+var v1Actions = []string{"fetch", "submit", "solves"}
+
+// reservedSettings configure the backend itself, as do env.* settings;
+// the rest are passed on to the script.
+var reservedSettings = []string{"command", "persistent", "heartbeat", "timeout", "workdir"}
+
+// scriptClient runs the script once per call, or keeps it running when
+// persistent is set.
 type scriptClient struct {
-	command []string
-	timeout time.Duration
+	command    *command
+	timeout    time.Duration
+	settings   map[string]string
+	persistent *persistent
+
+	helloMu sync.Mutex
+	hello   *scriptHelloResponse
 }
 
-func newScript(command string) (*scriptClient, error) {
-	parts := strings.Fields(command)
-	if len(parts) == 0 {
+func newScript(s map[string]string) (*scriptClient, error) {
+	args, err := splitCommand(s["command"])
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
 		return nil, fmt.Errorf("command is required")
 	}
-	return &scriptClient{
-		command: parts,
+	c := &scriptClient{
+		command: &command{args: args},
 		timeout: 2 * time.Minute,
-	}, nil
+	}
+
+	if v := s["workdir"]; v != "" {
+		dir, err := filepath.Abs(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid workdir %q: %w", v, err)
+		}
+		c.command.dir = dir
+	}
+	if v := s["timeout"]; v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q", v)
+		}
+		c.timeout = d
+	}
+	for k, v := range s {
+		if name, ok := strings.CutPrefix(k, "env."); ok {
+			if name == "" || strings.Contains(name, "=") {
+				return nil, fmt.Errorf("invalid environment variable name %q", name)
+			}
+			c.command.env = append(c.command.env, name+"="+v)
+		} else if !slices.Contains(reservedSettings, k) && !slices.Contains(sandboxSettings, k) {
+			if c.settings == nil {
+				c.settings = make(map[string]string)
+			}
+			c.settings[k] = v
+		}
+	}
+	slices.Sort(c.command.env)
+	if c.command.sandbox, err = newSandbox(s); err != nil {
+		return nil, err
+	}
+
+	if v := s["persistent"]; v != "" {
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid persistent %q: %w", v, err)
+		}
+		if on {
+			c.persistent = &persistent{command: c.command, heartbeat: 30 * time.Second}
+		}
+	}
+	if v := s["heartbeat"]; v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid heartbeat %q", v)
+		}
+		if c.persistent != nil {
+			c.persistent.heartbeat = d
+		}
+	}
+	return c, nil
+}
+
+// Close stops a persistent script and removes a sandboxed script's
+// scratch directory.
+func (c *scriptClient) Close() error {
+	var err error
+	if c.persistent != nil {
+		err = c.persistent.Close()
+	}
+	if c.command.sandbox != nil {
+		err = errors.Join(err, c.command.sandbox.close())
+	}
+	return err
+}
+
+// handshake asks the script, once, for its protocol version and actions.
+// A script that rejects hello, by exiting non-zero or answering with an
+// error, or that answers without a version speaks version 1. Other
+// failures, such as a timeout or a crashed persistent script, aren't
+// remembered, so the next call asks again.
+func (c *scriptClient) handshake(ctx context.Context) (*scriptHelloResponse, error) {
+	c.helloMu.Lock()
+	defer c.helloMu.Unlock()
+	if c.hello != nil {
+		return c.hello, nil
+	}
+
+	var resp scriptHelloResponse
+	output, err := c.run(ctx, "hello", scriptHelloRequest{scriptRequest: c.request("hello"), Protocol: protocolVersion})
+	if err == nil {
+		if json.Unmarshal(output, &resp) != nil {
+			resp = scriptHelloResponse{}
+		}
+	} else if !rejectedHello(err) {
+		return nil, fmt.Errorf("script hello: %w", err)
+	}
+	if resp.Protocol == 0 {
+		resp = scriptHelloResponse{Protocol: 1}
+	}
+	if resp.Protocol > protocolVersion {
+		return nil, fmt.Errorf("script speaks protocol version %d, newer than the supported %d", resp.Protocol, protocolVersion)
+	}
+	if len(resp.Actions) == 0 {
+		resp.Actions = v1Actions
+	}
+	c.hello = &resp
+	return c.hello, nil
+}
+
+// rejectedHello reports whether err is a version 1 script turning hello
+// down rather than a script that couldn't answer.
+func rejectedHello(err error) bool {
+	var scriptErr *Error
+	var exitErr *exec.ExitError
+	return errors.As(err, &scriptErr) || (errors.As(err, &exitErr) && !errors.Is(err, errExited))
+}
+
+// supports reports whether the script declared action in its handshake.
+func (c *scriptClient) supports(ctx context.Context, action string) (bool, error) {
+	hello, err := c.handshake(ctx)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(hello.Actions, action), nil
 }
 
 func (c *scriptClient) Fetch(ctx context.Context) ([]jeopardy.Challenge, error) {
-	payload := scriptFetchRequest{Action: "fetch"}
-	output, err := c.run(ctx, payload)
+	if ok, err := c.supports(ctx, "fetch"); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("script doesn't support fetch")
+	}
+	payload := scriptFetchRequest{c.request("fetch")}
+	output, err := c.run(ctx, payload.Action, payload)
 	if err != nil {
 		return nil, err
 	}
@@ -56,15 +215,35 @@ func (c *scriptClient) Fetch(ctx context.Context) ([]jeopardy.Challenge, error)
 	challenges := make([]jeopardy.Challenge, 0, len(resp.Challenges))
 	for _, ch := range resp.Challenges {
 		challenge := jeopardy.Challenge{
-			ID:          ch.ID,
-			Name:        ch.Name,
-			Category:    ch.Category,
-			Description: ch.Description,
-			Points:      ch.Points,
+			ID:       ch.ID,
+			Name:     ch.Name,
+			Category: ch.Category,
+			Points:   ch.Points,
+			Tags:     ch.Tags,
+			Solved:   ch.Solved,
+			Hints:    ch.Hints,
+		}
+		challenge.SetDescription(ch.Description)
+		if ch.ConnectionInfo != "" {
+			challenge.Endpoints = jeopardy.MergeEndpoints(jeopardy.ParseEndpoints(ch.ConnectionInfo), challenge.Endpoints)
 		}
 		if len(ch.Files) > 0 {
 			challenge.Files = make([]jeopardy.File, 0, len(ch.Files))
 			for _, f := range ch.Files {
+				if f.URL == "" {
+					// No URL: the script serves it through download.
+					id := f.ID
+					if id == "" {
+						id = f.Name
+					}
+					challenge.Files = append(challenge.Files, &servedFile{
+						client:      c,
+						challengeID: ch.ID,
+						id:          id,
+						name:        f.Name,
+					})
+					continue
+				}
 				challenge.Files = append(challenge.Files, &scriptFile{
 					name:    f.Name,
 					url:     f.URL,
@@ -78,12 +257,17 @@ func (c *scriptClient) Fetch(ctx context.Context) ([]jeopardy.Challenge, error)
 }
 
 func (c *scriptClient) Submit(ctx context.Context, challengeID, flag string) (*jeopardy.SubmitResult, error) {
+	if ok, err := c.supports(ctx, "submit"); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("script doesn't support submit")
+	}
 	payload := scriptSubmitRequest{
-		Action:      "submit",
-		ChallengeID: challengeID,
-		Flag:        flag,
+		scriptRequest: c.request("submit"),
+		ChallengeID:   challengeID,
+		Flag:          flag,
 	}
-	output, err := c.run(ctx, payload)
+	output, err := c.run(ctx, payload.Action, payload)
 	if err != nil {
 		return nil, err
 	}
@@ -97,9 +281,13 @@ func (c *scriptClient) Submit(ctx context.Context, challengeID, flag string) (*j
 	return &jeopardy.SubmitResult{Status: status, Message: resp.Message}, nil
 }
 
+// Solves returns nothing for scripts that don't support solves.
 func (c *scriptClient) Solves(ctx context.Context) ([]jeopardy.Solve, error) {
-	payload := scriptSolvesRequest{Action: "solves"}
-	output, err := c.run(ctx, payload)
+	if ok, err := c.supports(ctx, "solves"); err != nil || !ok {
+		return nil, err
+	}
+	payload := scriptSolvesRequest{c.request("solves")}
+	output, err := c.run(ctx, payload.Action, payload)
 	if err != nil {
 		return nil, err
 	}
@@ -111,7 +299,12 @@ func (c *scriptClient) Solves(ctx context.Context) ([]jeopardy.Solve, error) {
 
 	solves := make([]jeopardy.Solve, 0, len(resp.Solves))
 	for _, s := range resp.Solves {
-		solve := jeopardy.Solve{ChallengeID: s.ChallengeID}
+		solve := jeopardy.Solve{
+			ChallengeID: s.ChallengeID,
+			UserID:      s.UserID,
+			UserName:    s.UserName,
+			Points:      s.Points,
+		}
 		if s.SolvedAt != nil {
 			solve.SolvedAt = s.SolvedAt
 		}
@@ -120,7 +313,25 @@ func (c *scriptClient) Solves(ctx context.Context) ([]jeopardy.Solve, error) {
 	return solves, nil
 }
 
-func (c *scriptClient) run(ctx context.Context, payload any) ([]byte, error) {
+// request starts a request for action.
+func (c *scriptClient) request(action string) scriptRequest {
+	return scriptRequest{Action: action, Settings: c.settings}
+}
+
+// run sends payload to the script and returns its JSON response.
+func (c *scriptClient) run(ctx context.Context, action string, payload any) ([]byte, error) {
+	return c.stream(ctx, action, payload, nil)
+}
+
+// stream is run for actions whose output may come in chunks: objects with
+// a base64 "data" field printed ahead of the response by a one-shot
+// script, or "data" notifications from a persistent one. onData, if not
+// nil, gets each chunk.
+//
+// A persistent script that dies during a call is restarted and asked
+// again, unless the call was a submit, since resubmitting a flag isn't
+// safe, had already streamed data, or was killed for printing too much.
+func (c *scriptClient) stream(ctx context.Context, action string, payload any, onData func([]byte)) ([]byte, error) {
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("encode script request: %w", err)
@@ -129,19 +340,82 @@ func (c *scriptClient) run(ctx context.Context, payload any) ([]byte, error) {
 	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, c.command[0], c.command[1:]...)
-	cmd.Stdin = bytes.NewReader(data)
-	output, err := cmd.Output()
+	if c.persistent != nil {
+		output, err := c.persistent.call(ctx, action, data, onData)
+		if errors.Is(err, errExited) && !errors.Is(err, errOutputLimit) && action != "submit" && onData == nil {
+			output, err = c.persistent.call(ctx, action, data, nil)
+		}
+		return output, err
+	}
+
+	cmd, err := c.command.cmd(ctx)
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			stderr := strings.TrimSpace(string(exitErr.Stderr))
-			if stderr != "" {
-				return nil, fmt.Errorf("script error: %s", stderr)
+		return nil, err
+	}
+	cmd.Stdin = bytes.NewReader(data)
+	stderr := &stderrLog{}
+	cmd.Stderr = stderr
+	defer stderr.Flush()
+	if onData == nil {
+		stdout := &limitedBuffer{max: c.command.maxOutput(), onExceed: func() { cmd.Process.Kill() }}
+		cmd.Stdout = stdout
+		if err := cmd.Run(); err != nil {
+			if stdout.exceeded {
+				return nil, errOutputLimit
 			}
+			return nil, scriptError(ctx, action, err, stdout.buf)
 		}
+		return checkResponse(action, stdout.buf)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
 		return nil, fmt.Errorf("script error: %w", err)
 	}
-	return output, nil
+	var last json.RawMessage
+	dec := json.NewDecoder(&limitedReader{r: stdout, max: c.command.maxOutput()})
+	for {
+		var obj json.RawMessage
+		if err := dec.Decode(&obj); err != nil {
+			if err != io.EOF && ctx.Err() == nil {
+				cmd.Process.Kill()
+				cmd.Wait()
+				if errors.Is(err, errOutputLimit) {
+					return nil, err
+				}
+				return nil, fmt.Errorf("script's %s output isn't a sequence of JSON objects: %w", action, err)
+			}
+			break
+		}
+		var chunk struct {
+			Data []byte `json:"data"`
+		}
+		if json.Unmarshal(obj, &chunk) == nil && len(chunk.Data) > 0 {
+			onData(chunk.Data)
+		}
+		last = obj
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, scriptError(ctx, action, err, last)
+	}
+	return checkResponse(action, last)
+}
+
+// scriptError describes a run that exited non-zero. An error the script
+// answered with explains it better than the exit status; stderr has
+// already been logged. A run killed by ctx fails with ctx's error.
+func scriptError(ctx context.Context, action string, err error, output []byte) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("script %s: %w", action, ctx.Err())
+	}
+	var scriptErr *Error
+	if _, respErr := checkResponse(action, output); errors.As(respErr, &scriptErr) {
+		return scriptErr
+	}
+	return fmt.Errorf("script error: %w", err)
 }
 
 func parseSubmitStatus(s string) jeopardy.SubmitStatus {
@@ -173,8 +447,25 @@ func (f *scriptFile) DownloadURL(ctx context.Context) (*jeopardy.DownloadInfo, e
 	return &jeopardy.DownloadInfo{URL: f.url, Headers: f.headers}, nil
 }
 
+// scriptRequest has the fields every request carries: the action, and
+// the settings that aren't the backend's own.
+type scriptRequest struct {
+	Action   string            `json:"action"`
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+type scriptHelloRequest struct {
+	scriptRequest
+	Protocol int `json:"protocol"`
+}
+
+type scriptHelloResponse struct {
+	Protocol int      `json:"protocol"`
+	Actions  []string `json:"actions"`
+}
+
 type scriptFetchRequest struct {
-	Action string `json:"action"`
+	scriptRequest
 }
 
 type scriptFetchResponse struct {
@@ -188,16 +479,26 @@ type scriptChallenge struct {
 	Description string           `json:"description"`
 	Points      int              `json:"points"`
 	Files       []scriptFileInfo `json:"files"`
+
+	// Since protocol version 2.
+	Tags           []string `json:"tags"`
+	Solved         bool     `json:"solved"`
+	Hints          []string `json:"hints"`
+	ConnectionInfo string   `json:"connection_info"`
 }
 
 type scriptFileInfo struct {
 	Name    string            `json:"name"`
 	URL     string            `json:"url"`
 	Headers map[string]string `json:"headers"`
+
+	// ID is passed back to download for files without a URL; the name
+	// is used when it is empty.
+	ID string `json:"id"`
 }
 
 type scriptSubmitRequest struct {
-	Action      string `json:"action"`
+	scriptRequest
 	ChallengeID string `json:"challenge_id"`
 	Flag        string `json:"flag"`
 }
@@ -208,7 +509,7 @@ type scriptSubmitResponse struct {
 }
 
 type scriptSolvesRequest struct {
-	Action string `json:"action"`
+	scriptRequest
 }
 
 type scriptSolvesResponse struct {
@@ -218,4 +519,7 @@ type scriptSolvesResponse struct {
 type scriptSolve struct {
 	ChallengeID string     `json:"challenge_id"`
 	SolvedAt    *time.Time `json:"solved_at"`
+	UserID      string     `json:"user_id"`
+	UserName    string     `json:"user_name"`
+	Points      int        `json:"points"`
 }
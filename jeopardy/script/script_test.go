@@ -1,11 +1,269 @@
 package script
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/rw-r-r-0644/ctf-sync/jeopardy"
 )
 
+// TestMain lets the test binary stand in for a script: with
+// CTF_SYNC_TEST_SCRIPT set to oneshot or persistent it serves requests
+// with the handler CTF_SYNC_TEST_HANDLER names instead of running tests.
+func TestMain(m *testing.M) {
+	mode := os.Getenv("CTF_SYNC_TEST_SCRIPT")
+	if mode == "" {
+		// Race-enabled helpers would otherwise linger a second on exit.
+		os.Setenv("GORACE", "atexit_sleep_ms=0")
+		os.Exit(m.Run())
+	}
+	handle, ok := testScriptHandlers[os.Getenv("CTF_SYNC_TEST_HANDLER")]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown test handler %q\n", os.Getenv("CTF_SYNC_TEST_HANDLER"))
+		os.Exit(2)
+	}
+	switch mode {
+	case "oneshot":
+		var req map[string]any
+		json.NewDecoder(os.Stdin).Decode(&req)
+		result, err := handle(req["action"].(string), req, func(data []byte) {
+			json.NewEncoder(os.Stdout).Encode(map[string]any{"data": data})
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		json.NewEncoder(os.Stdout).Encode(result)
+	case "persistent":
+		testScriptServe(handle)
+	}
+	os.Exit(0)
+}
+
+// useTestScript makes the test binary run as the fake script handler, in
+// mode oneshot or persistent.
+func useTestScript(t *testing.T, mode, handler string) {
+	t.Setenv("CTF_SYNC_TEST_SCRIPT", mode)
+	t.Setenv("CTF_SYNC_TEST_HANDLER", handler)
+}
+
+// testScriptHandler answers an action for a fake script, streaming data
+// chunks through emit.
+type testScriptHandler func(action string, req map[string]any, emit func([]byte)) (any, error)
+
+// testScriptHandlers are the fake scripts a test can pick. Each answers
+// only the actions its tests need, so no test relies on another's.
+var testScriptHandlers = map[string]testScriptHandler{
+	"counter":    testCounterScript,
+	"v2":         testV2Script,
+	"hanghello":  testHangingHelloScript,
+	"crashhello": testCrashingHelloScript,
+	"settings":   testSettingsScript,
+	"errors":     testErrorScript,
+	"ping":       testPingScript,
+	"noping":     testNoPingScript,
+	"sandbox":    testSandboxScript,
+}
+
+func testUnknownAction(action string) error {
+	return fmt.Errorf("unknown action %s", action)
+}
+
+var (
+	testScriptFetches atomic.Int64
+	// testScriptRelease is closed by the first fetch, letting waiting
+	// submits finish.
+	testScriptRelease     = make(chan struct{})
+	testScriptReleaseOnce sync.Once
+)
+
+// testCountedFetch answers fetch with a challenge named after how many
+// fetches this process handled, so tests can tell whether it was
+// restarted.
+func testCountedFetch() map[string]any {
+	n := testScriptFetches.Add(1)
+	return map[string]any{"challenges": []map[string]any{{"id": "1", "name": fmt.Sprint(n)}}}
+}
+
+// testCounterScript counts fetches. Submitting "wait" streams a chunk, so
+// tests know it is in flight, and returns after the first fetch; "crash"
+// exits, and any other flag is rejected.
+func testCounterScript(action string, req map[string]any, emit func([]byte)) (any, error) {
+	switch action {
+	case "fetch":
+		testScriptReleaseOnce.Do(func() { close(testScriptRelease) })
+		return testCountedFetch(), nil
+	case "submit":
+		switch req["flag"] {
+		case "wait":
+			emit([]byte("waiting"))
+			<-testScriptRelease
+			return map[string]any{"status": "accepted"}, nil
+		case "crash":
+			fmt.Fprintln(os.Stderr, "boom")
+			os.Exit(3)
+		}
+		return map[string]any{"status": "rejected", "message": "nope"}, nil
+	}
+	return nil, testUnknownAction(action)
+}
+
+// testV2Script speaks protocol version 2 with the actions listed in
+// CTF_SYNC_TEST_ACTIONS, or version 1 without them. Its challenge has
+// every version 2 field and two files: download writes the first to the
+// path it is given and streams the second.
+func testV2Script(action string, req map[string]any, emit func([]byte)) (any, error) {
+	switch action {
+	case "hello":
+		if actions := os.Getenv("CTF_SYNC_TEST_ACTIONS"); actions != "" {
+			return map[string]any{"protocol": 2, "actions": strings.Split(actions, ",")}, nil
+		}
+	case "fetch":
+		return map[string]any{"challenges": []map[string]any{{
+			"id":              "1",
+			"name":            "v2",
+			"tags":            []string{"easy"},
+			"solved":          true,
+			"hints":           []string{"xor is its own inverse"},
+			"connection_info": "nc chall.example.org 1337",
+			"files":           []map[string]any{{"name": "written.txt"}, {"name": "streamed.txt", "id": "s"}},
+		}}}, nil
+	case "download":
+		if req["file"] == "s" {
+			emit([]byte("streamed "))
+			emit([]byte("by the script"))
+			return map[string]any{}, nil
+		}
+		return map[string]any{}, os.WriteFile(req["path"].(string), []byte("written by the script"), 0644)
+	}
+	return nil, testUnknownAction(action)
+}
+
+// testHangingHelloScript never answers hello.
+func testHangingHelloScript(action string, _ map[string]any, _ func([]byte)) (any, error) {
+	if action == "hello" {
+		time.Sleep(time.Minute)
+	}
+	return nil, testUnknownAction(action)
+}
+
+// testCrashingHelloScript exits on hello.
+func testCrashingHelloScript(action string, _ map[string]any, _ func([]byte)) (any, error) {
+	if action == "hello" {
+		os.Exit(3)
+	}
+	return nil, testUnknownAction(action)
+}
+
+// testSettingsScript describes its challenge with the settings, the
+// CTF_SYNC_TEST_VAR variable and the working directory it got. Submits
+// never return.
+func testSettingsScript(action string, req map[string]any, _ func([]byte)) (any, error) {
+	switch action {
+	case "fetch":
+		wd, _ := os.Getwd()
+		return map[string]any{"challenges": []map[string]any{{
+			"id":          "1",
+			"name":        "settings",
+			"description": fmt.Sprintf("settings=%v env=%s wd=%s", req["settings"], os.Getenv("CTF_SYNC_TEST_VAR"), wd),
+		}}}, nil
+	case "submit":
+		time.Sleep(time.Minute)
+	}
+	return nil, testUnknownAction(action)
+}
+
+// testErrorScript fails submits the way the flag says: "auth" answers an
+// auth error, "authexit" answers one and then exits non-zero, "crash"
+// logs "boom" and exits, "noisy" logs two lines first, and "garbage"
+// prints text before its response. Other flags are rejected.
+func testErrorScript(action string, req map[string]any, _ func([]byte)) (any, error) {
+	if action != "submit" {
+		return nil, testUnknownAction(action)
+	}
+	switch req["flag"] {
+	case "auth":
+		return map[string]any{"error": map[string]any{"kind": "auth", "message": "session expired"}}, nil
+	case "authexit":
+		// Only for one-shot scripts.
+		json.NewEncoder(os.Stdout).Encode(map[string]any{"error": map[string]any{"kind": "auth", "message": "session expired"}})
+		os.Exit(1)
+	case "crash":
+		fmt.Fprintln(os.Stderr, "boom")
+		os.Exit(3)
+	case "noisy":
+		fmt.Fprint(os.Stderr, "warning: slow login\nlogged in")
+	case "garbage":
+		fmt.Println("Logging in...")
+	}
+	return map[string]any{"status": "rejected", "message": "nope"}, nil
+}
+
+// testPingScript counts fetches and logs pings to stderr.
+func testPingScript(action string, _ map[string]any, _ func([]byte)) (any, error) {
+	switch action {
+	case "fetch":
+		return testCountedFetch(), nil
+	case "ping":
+		fmt.Fprintln(os.Stderr, "debug: ping")
+		return map[string]any{}, nil
+	}
+	return nil, testUnknownAction(action)
+}
+
+// testNoPingScript counts fetches and never answers a ping.
+func testNoPingScript(action string, _ map[string]any, _ func([]byte)) (any, error) {
+	switch action {
+	case "fetch":
+		return testCountedFetch(), nil
+	case "ping":
+		select {}
+	}
+	return nil, testUnknownAction(action)
+}
+
+func testScriptServe(handle testScriptHandler) {
+	var mu sync.Mutex
+	out := json.NewEncoder(os.Stdout)
+	r := bufio.NewReader(os.Stdin)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return
+		}
+		var req struct {
+			ID     int64          `json:"id"`
+			Method string         `json:"method"`
+			Params map[string]any `json:"params"`
+		}
+		json.Unmarshal(line, &req)
+		go func() {
+			result, err := handle(req.Method, req.Params, func(data []byte) {
+				mu.Lock()
+				out.Encode(map[string]any{"jsonrpc": "2.0", "method": "data", "params": map[string]any{"id": req.ID, "data": data}})
+				mu.Unlock()
+			})
+			resp := map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result}
+			if err != nil {
+				resp = map[string]any{"jsonrpc": "2.0", "id": req.ID, "error": map[string]any{"code": -32601, "message": err.Error()}}
+			}
+			mu.Lock()
+			out.Encode(resp)
+			mu.Unlock()
+		}()
+	}
+}
+
 func TestScriptBackendRegistered(t *testing.T) {
 	backends := jeopardy.Backends()
 	for _, b := range backends {
@@ -27,3 +285,164 @@ func TestBuildScript(t *testing.T) {
 		t.Fatal("backend is nil")
 	}
 }
+
+func TestOneShot(t *testing.T) {
+	useTestScript(t, "oneshot", "counter")
+	b, err := jeopardy.Build("script", map[string]string{"command": os.Args[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		challenges, err := b.Fetch(ctx)
+		if err != nil {
+			t.Fatalf("Fetch failed: %v", err)
+		}
+		if len(challenges) != 1 || challenges[0].Name != "1" {
+			t.Errorf("challenges = %+v, want a fresh process each time", challenges)
+		}
+	}
+	res, err := b.Submit(ctx, "1", "flag{x}")
+	if err != nil || res.Status != jeopardy.Rejected || res.Message != "nope" {
+		t.Errorf("Submit = %+v, %v", res, err)
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	useTestScript(t, "oneshot", "v2")
+	ctx := context.Background()
+
+	// Version 1: hello fails, every action is assumed.
+	c, _ := newScript(map[string]string{"command": os.Args[0]})
+	if hello, err := c.handshake(ctx); err != nil || hello.Protocol != 1 || len(hello.Actions) != 3 {
+		t.Errorf("v1 handshake = %+v, %v", hello, err)
+	}
+
+	t.Setenv("CTF_SYNC_TEST_ACTIONS", "fetch")
+	c, _ = newScript(map[string]string{"command": os.Args[0]})
+	challenges, err := c.Fetch(ctx)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	ch := challenges[0]
+	if len(ch.Tags) != 1 || !ch.Solved || len(ch.Hints) != 1 || len(ch.Endpoints) != 1 || ch.Endpoints[0].Port != 1337 {
+		t.Errorf("v2 fields not filled: %+v", ch)
+	}
+	if _, err := c.Submit(ctx, "1", "flag{x}"); err == nil || !strings.Contains(err.Error(), "doesn't support submit") {
+		t.Errorf("unsupported Submit = %v", err)
+	}
+	if solves, err := c.Solves(ctx); solves != nil || err != nil {
+		t.Errorf("unsupported Solves = %+v, %v", solves, err)
+	}
+}
+
+func TestHandshakeNotRemembered(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct{ mode, handler string }{{"oneshot", "hanghello"}, {"persistent", "crashhello"}} {
+		t.Run(tc.mode, func(t *testing.T) {
+			useTestScript(t, tc.mode, tc.handler)
+			c, err := newScript(map[string]string{"command": os.Args[0], "persistent": fmt.Sprint(tc.mode == "persistent"), "timeout": "1s"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			if _, err := c.handshake(ctx); err == nil || !(errors.Is(err, context.DeadlineExceeded) || errors.Is(err, errExited)) {
+				t.Errorf("failed hello = %v", err)
+			}
+			useTestScript(t, tc.mode, "v2")
+			t.Setenv("CTF_SYNC_TEST_ACTIONS", "fetch")
+			if hello, err := c.handshake(ctx); err != nil || hello.Protocol != 2 {
+				t.Errorf("handshake after a failed hello = %+v, %v", hello, err)
+			}
+		})
+	}
+}
+
+func TestScriptSettings(t *testing.T) {
+	useTestScript(t, "oneshot", "settings")
+	dir := t.TempDir()
+	c, err := newScript(map[string]string{
+		"command":               `"` + os.Args[0] + `" -test.run=^$`,
+		"workdir":               dir,
+		"timeout":               "1s",
+		"env.CTF_SYNC_TEST_VAR": "hunter2",
+		"base_url":              "https://ctf.example.com",
+		"token":                 "s3cret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	challenges, err := c.Fetch(ctx)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	want := "settings=map[base_url:https://ctf.example.com token:s3cret] env=hunter2 wd=" + dir
+	if got := challenges[0].Description; got != want {
+		t.Errorf("script saw %q, want %q", got, want)
+	}
+
+	if _, err := c.Submit(ctx, "1", "hang"); err == nil {
+		t.Error("Submit outlived the timeout")
+	}
+
+	for _, s := range []map[string]string{
+		{"command": `python3 "sync.py`},
+		{"command": "python3 sync.py", "timeout": "soon"},
+		{"command": "python3 sync.py", "env.": "x"},
+	} {
+		if _, err := newScript(s); err == nil {
+			t.Errorf("newScript(%v) succeeded", s)
+		}
+	}
+}
+
+func TestDownload(t *testing.T) {
+	t.Setenv("CTF_SYNC_TEST_ACTIONS", "fetch,download")
+	for _, mode := range []string{"oneshot", "persistent"} {
+		t.Run(mode, func(t *testing.T) {
+			useTestScript(t, mode, "v2")
+			b, err := jeopardy.Build("script", map[string]string{"command": os.Args[0], "persistent": fmt.Sprint(mode == "persistent")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer b.(io.Closer).Close()
+			ctx := context.Background()
+			challenges, err := b.Fetch(ctx)
+			if err != nil {
+				t.Fatalf("Fetch failed: %v", err)
+			}
+			files := challenges[0].Files
+			if len(files) != 2 {
+				t.Fatalf("files = %v", files)
+			}
+			if _, err := files[0].DownloadURL(ctx); err == nil {
+				t.Error("DownloadURL succeeded for a script-served file")
+			}
+			for i, want := range []string{"written by the script", "streamed by the script"} {
+				r, err := jeopardy.OpenFile(ctx, files[i])
+				if err != nil {
+					t.Fatalf("OpenFile(%s) failed: %v", files[i].Name(), err)
+				}
+				data, _ := io.ReadAll(r)
+				r.Close()
+				if string(data) != want {
+					t.Errorf("%s = %q, want %q", files[i].Name(), data, want)
+				}
+			}
+		})
+	}
+
+	// Without download declared, there is no way to get the file.
+	useTestScript(t, "oneshot", "v2")
+	t.Setenv("CTF_SYNC_TEST_ACTIONS", "")
+	b, _ := jeopardy.Build("script", map[string]string{"command": os.Args[0]})
+	challenges, err := b.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jeopardy.OpenFile(context.Background(), challenges[0].Files[0]); err == nil {
+		t.Error("OpenFile succeeded without download support")
+	}
+}
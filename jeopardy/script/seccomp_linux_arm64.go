@@ -0,0 +1,40 @@
+package script
+
+// auditArch is AUDIT_ARCH_AARCH64.
+const auditArch = 0xc00000b7
+
+// deniedSyscalls are the syscalls a sandboxed script gets EPERM for; see
+// seccomp_linux_amd64.go.
+var deniedSyscalls = []uint32{
+	40,  // mount
+	39,  // umount2
+	41,  // pivot_root
+	117, // ptrace
+	104, // kexec_load
+	294, // kexec_file_load
+	105, // init_module
+	273, // finit_module
+	106, // delete_module
+	280, // bpf
+	219, // keyctl
+	217, // add_key
+	218, // request_key
+	97,  // unshare
+	268, // setns
+	142, // reboot
+	224, // swapon
+	225, // swapoff
+	241, // perf_event_open
+	282, // userfaultfd
+	265, // open_by_handle_at
+	89,  // acct
+	270, // process_vm_readv
+	271, // process_vm_writev
+	428, // open_tree
+	429, // move_mount
+	430, // fsopen
+	431, // fsconfig
+	432, // fsmount
+	433, // fspick
+	442, // mount_setattr
+}
@@ -0,0 +1,9 @@
+//go:build linux && !amd64 && !arm64
+
+package script
+
+// There is no seccomp filter for this architecture yet; installSeccomp
+// warns and the script runs without one.
+const auditArch = 0
+
+var deniedSyscalls []uint32
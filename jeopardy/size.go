@@ -0,0 +1,27 @@
+package jeopardy
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseSize parses a byte count like 4096, 100B, 512K, 64M, 2G or 1T
+// (powers of 1024, case-insensitive, with an optional trailing B).
+func ParseSize(v string) (int64, error) {
+	s := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(v)), "B")
+	shift := 0
+	for i, suffix := range []string{"K", "M", "G", "T"} {
+		if trimmed, ok := strings.CutSuffix(s, suffix); ok {
+			s = trimmed
+			shift = 10 * (i + 1)
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64>>shift {
+		return 0, fmt.Errorf("invalid size %q, want one like 512M", v)
+	}
+	return n << shift, nil
+}
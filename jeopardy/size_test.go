@@ -0,0 +1,29 @@
+package jeopardy
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"4096", 4096},
+		{"100B", 100},
+		{"512K", 512 << 10},
+		{"64M", 64 << 20},
+		{"2g", 2 << 30},
+		{"1GB", 1 << 30},
+		{"8T", 8 << 40},
+		{"8388607T", 8388607 << 40},
+	} {
+		if got, err := ParseSize(tt.in); err != nil || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "M", "-1", "lots", "1P", "8388608T", "99999999999T", "9223372036854775808"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("ParseSize(%q) succeeded", in)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package jeopardy
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiDim       = "\x1b[2m"
+	ansiItalic    = "\x1b[3m"
+	ansiUnderline = "\x1b[4m"
+	ansiCyan      = "\x1b[36m"
+)
+
+var (
+	headingRe  = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	bulletRe   = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	hrRe       = regexp.MustCompile(`^\s*([-*_])(\s*[-*_]){2,}\s*$`)
+	codeSpanRe = regexp.MustCompile("`([^`]+)`")
+	imageRe    = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)[^)]*\)`)
+	boldRe     = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	italicRe   = regexp.MustCompile(`\*([^*\s][^*]*)\*|\b_([^_\s][^_]*)_\b`)
+	tagRe      = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+)
+
+// RenderTerminal formats Markdown (as produced by ParseDescription) as
+// plain text for a terminal, using ANSI styles when color is true.
+func RenderTerminal(md string, color bool) string {
+	style := func(s string, codes ...string) string {
+		if !color || s == "" {
+			return s
+		}
+		return strings.Join(codes, "") + s + ansiReset
+	}
+
+	var out []string
+	inCode := false
+	for _, line := range strings.Split(md, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			out = append(out, "    "+style(line, ansiCyan))
+			continue
+		}
+
+		line = strings.TrimSuffix(strings.TrimRight(line, " "), `\`)
+		switch {
+		case hrRe.MatchString(line):
+			out = append(out, style(strings.Repeat("─", 40), ansiDim))
+		case headingRe.MatchString(line):
+			m := headingRe.FindStringSubmatch(line)
+			codes := []string{ansiBold}
+			if len(m[1]) <= 2 {
+				codes = append(codes, ansiUnderline)
+			}
+			out = append(out, style(renderInline(m[2], style), codes...))
+		case strings.HasPrefix(line, ">"):
+			text := strings.TrimSpace(strings.TrimPrefix(line, ">"))
+			out = append(out, style("│ ", ansiDim)+renderInline(text, style))
+		case bulletRe.MatchString(line):
+			m := bulletRe.FindStringSubmatch(line)
+			out = append(out, m[1]+"  • "+renderInline(m[2], style))
+		default:
+			out = append(out, renderInline(line, style))
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+func renderInline(s string, style func(string, ...string) string) string {
+	// Code spans are rendered first and shielded from the other rules.
+	var spans []string
+	s = codeSpanRe.ReplaceAllStringFunc(s, func(m string) string {
+		spans = append(spans, style(codeSpanRe.FindStringSubmatch(m)[1], ansiCyan))
+		return "\x00" + strconv.Itoa(len(spans)-1) + "\x00"
+	})
+
+	s = imageRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := imageRe.FindStringSubmatch(m)
+		return style("[image: "+nonEmpty(sub[1], "untitled")+"]", ansiDim) + " " + sub[2]
+	})
+	s = mdLinkRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := mdLinkRe.FindStringSubmatch(m)
+		if sub[1] == sub[2] || sub[1] == "" {
+			return style(sub[2], ansiUnderline)
+		}
+		return style(sub[1], ansiUnderline) + " " + style("("+sub[2]+")", ansiDim)
+	})
+	s = boldRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := boldRe.FindStringSubmatch(m)
+		return style(sub[1]+sub[2], ansiBold)
+	})
+	s = italicRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := italicRe.FindStringSubmatch(m)
+		return style(sub[1]+sub[2], ansiItalic)
+	})
+	s = html.UnescapeString(tagRe.ReplaceAllString(s, ""))
+
+	for i, span := range spans {
+		s = strings.Replace(s, "\x00"+strconv.Itoa(i)+"\x00", span, 1)
+	}
+	return s
+}
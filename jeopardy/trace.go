@@ -0,0 +1,406 @@
+package jeopardy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxTraceBody is how much of each body a Tracer keeps by default.
+const DefaultMaxTraceBody = 4096
+
+// Tracer records the HTTP traffic of the built-in backends. Attach it to
+// the context passed to Fetch, Submit, Solves and downloads with
+// WithTracer. Credentials (auth headers, cookies, tokens in query strings
+// and bodies) are redacted before anything is kept or logged.
+type Tracer struct {
+	// Log, if set, receives every exchange as it completes.
+	Log *slog.Logger
+	// MaxBody limits the bytes kept of each body; 0 means
+	// DefaultMaxTraceBody and a negative value keeps no body.
+	MaxBody int
+
+	mu      sync.Mutex
+	entries []TraceEntry
+}
+
+// TraceEntry is one HTTP exchange, already redacted.
+type TraceEntry struct {
+	Start          time.Time
+	Duration       time.Duration
+	Method         string
+	URL            string
+	RequestHeader  http.Header
+	RequestBody    string
+	Status         int
+	StatusText     string
+	ResponseHeader http.Header
+	ResponseBody   string
+	ResponseSize   int64
+	Truncated      bool
+	Err            string
+}
+
+type tracerKey struct{}
+
+// WithTracer returns a context whose HTTP requests are recorded by t.
+func WithTracer(ctx context.Context, t *Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+func tracerFrom(ctx context.Context) *Tracer {
+	t, _ := ctx.Value(tracerKey{}).(*Tracer)
+	return t
+}
+
+// Entries returns the exchanges recorded so far.
+func (t *Tracer) Entries() []TraceEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TraceEntry(nil), t.entries...)
+}
+
+func (t *Tracer) maxBody() int {
+	if t.MaxBody == 0 {
+		return DefaultMaxTraceBody
+	}
+	return max(t.MaxBody, 0)
+}
+
+func (t *Tracer) add(e TraceEntry) {
+	t.mu.Lock()
+	t.entries = append(t.entries, e)
+	t.mu.Unlock()
+
+	if t.Log == nil {
+		return
+	}
+	attrs := []any{
+		slog.String("method", e.Method),
+		slog.String("url", e.URL),
+		slog.Int("status", e.Status),
+		slog.Duration("duration", e.Duration),
+	}
+	if e.RequestBody != "" {
+		attrs = append(attrs, slog.String("request_body", e.RequestBody))
+	}
+	if e.ResponseBody != "" {
+		attrs = append(attrs, slog.String("response_body", e.ResponseBody))
+	}
+	if e.Err != "" {
+		attrs = append(attrs, slog.String("err", e.Err))
+	}
+	t.Log.Debug("http", attrs...)
+}
+
+// tracingTransport hands every request made with a traced context to its
+// Tracer. Requests without one go straight to the base transport.
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+// defaultTransport is used by every built-in backend and download.
+var defaultTransport http.RoundTripper = &tracingTransport{base: http.DefaultTransport}
+
+func (tt *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t := tracerFrom(req.Context())
+	if t == nil {
+		return tt.base.RoundTrip(req)
+	}
+
+	e := TraceEntry{
+		Start:         time.Now(),
+		Method:        req.Method,
+		URL:           redactURL(req.URL),
+		RequestHeader: redactHeader(req.Header),
+	}
+	if req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(io.LimitReader(body, int64(t.maxBody())+1))
+			body.Close()
+			e.RequestBody, _ = truncateBody(data, t.maxBody())
+			e.RequestBody = redactBody(e.RequestBody, req.Header.Get("Content-Type"))
+		}
+	}
+
+	resp, err := tt.base.RoundTrip(req)
+	if err != nil {
+		e.Duration = time.Since(e.Start)
+		e.Err = err.Error()
+		t.add(e)
+		return nil, err
+	}
+	e.Status = resp.StatusCode
+	e.StatusText = http.StatusText(resp.StatusCode)
+	e.ResponseHeader = redactHeader(resp.Header)
+	resp.Body = &traceBody{ReadCloser: resp.Body, tracer: t, entry: e, contentType: resp.Header.Get("Content-Type")}
+	return resp, nil
+}
+
+// traceBody keeps the start of a response body and records the exchange
+// when the body is closed, so large downloads are never buffered.
+type traceBody struct {
+	io.ReadCloser
+	tracer      *Tracer
+	entry       TraceEntry
+	contentType string
+	buf         bytes.Buffer
+	once        sync.Once
+}
+
+func (b *traceBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.entry.ResponseSize += int64(n)
+	if room := b.tracer.maxBody() + 1 - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(n, room)])
+	}
+	return n, err
+}
+
+func (b *traceBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		e := b.entry
+		e.Duration = time.Since(e.Start)
+		e.ResponseBody, e.Truncated = truncateBody(b.buf.Bytes(), b.tracer.maxBody())
+		e.ResponseBody = redactBody(e.ResponseBody, b.contentType)
+		b.tracer.add(e)
+	})
+	return err
+}
+
+func truncateBody(data []byte, limit int) (string, bool) {
+	if len(data) <= limit {
+		return string(data), false
+	}
+	return string(data[:limit]), true
+}
+
+// Redacted replaces credentials in traces.
+const Redacted = "[REDACTED]"
+
+// secretNameRe matches header, query, form and JSON field names that carry
+// credentials: tokens (CTFd, rCTF teamToken/authToken, CCIT auth), cookies,
+// sessions, CSRF nonces and passwords.
+var secretNameRe = regexp.MustCompile(`(?i)token|authoriz|cookie|session|csrf|nonce|passw|secret|api[-_]?key`)
+
+// isSecretName reports whether a header, parameter or field name carries a
+// credential. Plain "auth" is CCIT's download parameter; "author" is not.
+func isSecretName(name string) bool {
+	return strings.EqualFold(name, "auth") || secretNameRe.MatchString(name)
+}
+
+func redactHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for name := range out {
+		if isSecretName(name) {
+			out[name] = []string{Redacted}
+		}
+	}
+	return out
+}
+
+func redactURL(u *url.URL) string {
+	c := *u
+	c.User = nil
+	if c.RawQuery != "" {
+		c.RawQuery = redactQuery(c.RawQuery)
+	}
+	return c.String()
+}
+
+func redactQuery(raw string) string {
+	q, err := url.ParseQuery(raw)
+	if err != nil {
+		return Redacted
+	}
+	for key := range q {
+		if isSecretName(key) {
+			q[key] = []string{Redacted}
+		}
+	}
+	return q.Encode()
+}
+
+var (
+	// jsonStringRe matches "name": "value" pairs, also when truncation cut
+	// the closing quote off.
+	jsonStringRe = regexp.MustCompile(`"([^"\\]*)"(\s*:\s*")((?:[^"\\]|\\.)*)("?)`)
+	// queryParamRe matches parameters in URLs embedded in bodies, such as
+	// the signed tokens on CTFd file links.
+	queryParamRe = regexp.MustCompile(`([?&]([\w.-]+)=)([^&"'\s<>#\\]*)`)
+	// nonceScriptRe matches the csrfNonce CTFd embeds in its pages.
+	nonceScriptRe = regexp.MustCompile(`(?i)(csrf_?nonce['"]?\s*[:=]\s*['"])([^'"]*)`)
+)
+
+func redactBody(body, contentType string) string {
+	if body == "" {
+		return body
+	}
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		return redactQuery(body)
+	}
+	body = jsonStringRe.ReplaceAllStringFunc(body, func(m string) string {
+		sub := jsonStringRe.FindStringSubmatch(m)
+		if !isSecretName(sub[1]) {
+			return m
+		}
+		return `"` + sub[1] + `"` + sub[2] + Redacted + sub[4]
+	})
+	body = queryParamRe.ReplaceAllStringFunc(body, func(m string) string {
+		sub := queryParamRe.FindStringSubmatch(m)
+		if !isSecretName(sub[2]) {
+			return m
+		}
+		return sub[1] + url.QueryEscape(Redacted)
+	})
+	return nonceScriptRe.ReplaceAllString(body, "${1}"+Redacted)
+}
+
+// WriteHAR writes the recorded exchanges as an HTTP Archive (HAR 1.2),
+// which browsers' developer tools and most HTTP debuggers can open.
+func (t *Tracer) WriteHAR(w io.Writer) error {
+	entries := t.Entries()
+	har := harLog{Version: "1.2", Creator: harCreator{Name: "ctf-sync", Version: "1"}, Entries: []harEntry{}}
+	for _, e := range entries {
+		ms := float64(e.Duration.Microseconds()) / 1000
+		he := harEntry{
+			StartedDateTime: e.Start.Format("2006-01-02T15:04:05.000Z07:00"),
+			Time:            ms,
+			Request: harRequest{
+				Method:      e.Method,
+				URL:         e.URL,
+				HTTPVersion: "HTTP/1.1",
+				Headers:     harHeaders(e.RequestHeader),
+				QueryString: []harPair{},
+				Cookies:     []harPair{},
+				HeadersSize: -1,
+				BodySize:    len(e.RequestBody),
+			},
+			Response: harResponse{
+				Status:      e.Status,
+				StatusText:  e.StatusText,
+				HTTPVersion: "HTTP/1.1",
+				Headers:     harHeaders(e.ResponseHeader),
+				Cookies:     []harPair{},
+				Content: harContent{
+					Size:     e.ResponseSize,
+					MimeType: e.ResponseHeader.Get("Content-Type"),
+					Text:     e.ResponseBody,
+				},
+				RedirectURL: e.ResponseHeader.Get("Location"),
+				HeadersSize: -1,
+				BodySize:    e.ResponseSize,
+			},
+			Cache:   struct{}{},
+			Timings: harTimings{Send: 0, Wait: ms, Receive: 0},
+			Error:   e.Err,
+		}
+		if u, err := url.Parse(e.URL); err == nil {
+			for name, values := range u.Query() {
+				for _, v := range values {
+					he.Request.QueryString = append(he.Request.QueryString, harPair{Name: name, Value: v})
+				}
+			}
+		}
+		if e.RequestBody != "" {
+			he.Request.PostData = &harPostData{MimeType: e.RequestHeader.Get("Content-Type"), Text: e.RequestBody}
+		}
+		if e.Truncated {
+			he.Response.Content.Comment = "truncated"
+		}
+		har.Entries = append(har.Entries, he)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Log harLog `json:"log"`
+	}{har})
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Error           string      `json:"_error,omitempty"`
+}
+
+type harRequest struct {
+	Method      string       `json:"method"`
+	URL         string       `json:"url"`
+	HTTPVersion string       `json:"httpVersion"`
+	Headers     []harPair    `json:"headers"`
+	QueryString []harPair    `json:"queryString"`
+	Cookies     []harPair    `json:"cookies"`
+	PostData    *harPostData `json:"postData,omitempty"`
+	HeadersSize int          `json:"headersSize"`
+	BodySize    int          `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int        `json:"status"`
+	StatusText  string     `json:"statusText"`
+	HTTPVersion string     `json:"httpVersion"`
+	Headers     []harPair  `json:"headers"`
+	Cookies     []harPair  `json:"cookies"`
+	Content     harContent `json:"content"`
+	RedirectURL string     `json:"redirectURL"`
+	HeadersSize int        `json:"headersSize"`
+	BodySize    int64      `json:"bodySize"`
+}
+
+type harPair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func harHeaders(h http.Header) []harPair {
+	pairs := []harPair{}
+	for name, values := range h {
+		for _, v := range values {
+			pairs = append(pairs, harPair{Name: name, Value: v})
+		}
+	}
+	return pairs
+}
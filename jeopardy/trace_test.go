@@ -0,0 +1,97 @@
+package jeopardy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTracerRedactsCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "cookie-secret"})
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"kind": "goodLogin", "data": {"authToken": "auth-secret"}}`)
+	}))
+	defer srv.Close()
+
+	tracer := &Tracer{}
+	ctx := WithTracer(context.Background(), tracer)
+	body := `{"teamToken": "team-secret", "author": "bob"}`
+	req, _ := http.NewRequestWithContext(ctx, "POST", srv.URL+"/api/v1/auth/login?auth=query-secret&page=2", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer header-secret")
+	req.Header.Set("Cookie", "session=cookie-secret")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := (&http.Client{Transport: defaultTransport}).Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	var har bytes.Buffer
+	if err := tracer.WriteHAR(&har); err != nil {
+		t.Fatalf("WriteHAR failed: %v", err)
+	}
+	out := har.String()
+	for _, secret := range []string{"team-secret", "auth-secret", "cookie-secret", "header-secret", "query-secret"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("HAR contains %s", secret)
+		}
+	}
+	for _, kept := range []string{`\"author\": \"bob\"`, "page=2", "goodLogin"} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("HAR lacks %s", kept)
+		}
+	}
+
+	var parsed struct {
+		Log struct {
+			Entries []struct {
+				Request  struct{ Method string }
+				Response struct{ Status int }
+			}
+		}
+	}
+	if err := json.Unmarshal(har.Bytes(), &parsed); err != nil {
+		t.Fatalf("HAR is not valid JSON: %v", err)
+	}
+	if len(parsed.Log.Entries) != 1 || parsed.Log.Entries[0].Request.Method != "POST" || parsed.Log.Entries[0].Response.Status != 200 {
+		t.Errorf("unexpected HAR entries: %+v", parsed.Log.Entries)
+	}
+}
+
+func TestTracerTruncatesBodies(t *testing.T) {
+	content := strings.Repeat("x", 10000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, content)
+	}))
+	defer srv.Close()
+
+	tracer := &Tracer{MaxBody: 100}
+	ctx := WithTracer(context.Background(), tracer)
+	r, err := OpenFile(ctx, &urlFile{name: "big.bin", url: srv.URL})
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if len(data) != len(content) {
+		t.Fatalf("read %d bytes, want %d", len(data), len(content))
+	}
+
+	entries := tracer.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if !e.Truncated || len(e.ResponseBody) != 100 || e.ResponseSize != 10000 {
+		t.Errorf("truncated=%v body=%d size=%d", e.Truncated, len(e.ResponseBody), e.ResponseSize)
+	}
+}
@@ -15,6 +15,20 @@ type Challenge struct {
 	Tags        []string
 	Files       []File
 	Solved      bool
+
+	// Hints are the hints the platform shows: free ones, or those already
+	// unlocked.
+	Hints []string
+
+	// Extracted from Description by SetDescription. Connections are the
+	// nc/ssh/telnet commands among the description's Endpoints.
+	Links       []Link
+	FileLinks   []string
+	Connections []string
+
+	// Endpoints lists the services to connect to, from the description
+	// and any platform-specific connection info.
+	Endpoints []Endpoint
 }
 
 // File represents a challenge attachment.
@@ -49,7 +63,11 @@ type SubmitResult struct {
 }
 
 // Solve represents a solved challenge.
+// UserID, UserName and Points are left empty when the platform doesn't report them.
 type Solve struct {
 	ChallengeID string
 	SolvedAt    *time.Time
+	UserID      string
+	UserName    string
+	Points      int
 }
@@ -0,0 +1,136 @@
+package jeopardy
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// errZipPassword means the password failed the ZipCrypto header check.
+var errZipPassword = errors.New("wrong zip password")
+
+// errZipChecksum means an entry decrypted to data with the wrong CRC,
+// which for encrypted entries usually means a wrong password that
+// slipped past the one-byte header check.
+var errZipChecksum = errors.New("zip checksum mismatch")
+
+// zipCryptoKeys is the traditional PKWARE stream cipher state. It's weak,
+// but it's what "zip -P infected" produces and what CTF handouts use.
+type zipCryptoKeys [3]uint32
+
+func newZipCryptoKeys(password string) *zipCryptoKeys {
+	k := &zipCryptoKeys{0x12345678, 0x23456789, 0x34567890}
+	for i := 0; i < len(password); i++ {
+		k.update(password[i])
+	}
+	return k
+}
+
+func crc32Update(crc uint32, b byte) uint32 {
+	return (crc >> 8) ^ crc32.IEEETable[byte(crc)^b]
+}
+
+func (k *zipCryptoKeys) update(b byte) {
+	k[0] = crc32Update(k[0], b)
+	k[1] = (k[1]+(k[0]&0xff))*134775813 + 1
+	k[2] = crc32Update(k[2], byte(k[1]>>24))
+}
+
+func (k *zipCryptoKeys) decrypt(buf []byte) {
+	for i, c := range buf {
+		t := k[2] | 2
+		p := c ^ byte((t*(t^1))>>8)
+		k.update(p)
+		buf[i] = p
+	}
+}
+
+type zipCryptoReader struct {
+	r    io.Reader
+	keys *zipCryptoKeys
+}
+
+func (z *zipCryptoReader) Read(p []byte) (int, error) {
+	n, err := z.r.Read(p)
+	z.keys.decrypt(p[:n])
+	return n, err
+}
+
+// openZipEntry opens f for reading, decrypting it with password if it's a
+// ZipCrypto-encrypted entry. Encrypted entries are checked against their
+// CRC at EOF, returning errZipChecksum on mismatch.
+func openZipEntry(f *zip.File, password string) (io.ReadCloser, error) {
+	if f.Flags&0x1 == 0 {
+		return f.Open()
+	}
+	if f.Method == 99 {
+		return nil, fmt.Errorf("%s: AES-encrypted zip entries are not supported", f.Name)
+	}
+
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	keys := newZipCryptoKeys(password)
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(raw, header); err != nil {
+		return nil, fmt.Errorf("%s: read encryption header: %w", f.Name, err)
+	}
+	keys.decrypt(header)
+
+	// The last header byte repeats the CRC's high byte, or the DOS mod
+	// time's when sizes are deferred to a data descriptor.
+	check := byte(f.CRC32 >> 24)
+	if f.Flags&0x8 != 0 {
+		// ModifiedTime is deprecated in favour of Modified, but the reader
+		// prefers an extended timestamp for Modified and rounds its zone to
+		// 15 minutes, so the DOS time can't be rebuilt from it. Reading
+		// still fills ModifiedTime, which is what the writer encrypted.
+		check = byte(f.ModifiedTime >> 8) //nolint:staticcheck // SA1019: see above
+	}
+	if header[11] != check {
+		return nil, errZipPassword
+	}
+
+	plain := io.Reader(&zipCryptoReader{r: raw, keys: keys})
+	var rc io.ReadCloser
+	switch f.Method {
+	case zip.Store:
+		rc = io.NopCloser(plain)
+	case zip.Deflate:
+		rc = flate.NewReader(plain)
+	default:
+		return nil, fmt.Errorf("%s: unsupported compression method %d", f.Name, f.Method)
+	}
+	return &zipChecksumReader{rc: rc, hash: crc32.NewIEEE(), want: f.CRC32, size: f.UncompressedSize64}, nil
+}
+
+type zipChecksumReader struct {
+	rc   io.ReadCloser
+	hash hash.Hash32
+	want uint32
+	size uint64
+	read uint64
+}
+
+func (z *zipChecksumReader) Read(p []byte) (int, error) {
+	n, err := z.rc.Read(p)
+	z.hash.Write(p[:n])
+	z.read += uint64(n)
+	if err == io.EOF && (z.read != z.size || z.hash.Sum32() != z.want) {
+		return n, errZipChecksum
+	}
+	if err != nil && err != io.EOF {
+		// A wrong password usually surfaces as corrupt deflate data.
+		return n, fmt.Errorf("%w: %v", errZipChecksum, err)
+	}
+	return n, err
+}
+
+func (z *zipChecksumReader) Close() error {
+	return z.rc.Close()
+}